@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"github.com/gdamore/tcell/v2"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	RE_tomlHeader  = regexp.MustCompile(`^\[+\s*([\w.]+)\s*\]+`)
+	RE_tomlAssn    = regexp.MustCompile(`^(\w+)\s*=\s*(.*)`)
+	RE_yamlLine    = regexp.MustCompile(`^(\s*)(?:-\s*)?(\w+):\s*(.*)`)
+	RE_acIndex     = regexp.MustCompile(`\bindex\s*[=:]\s*(\d+)`)
+	RE_acColor     = regexp.MustCompile(`\bcolor\s*[=:]\s*["']?(#|0x)([0-9A-Fa-f]{6})`)
+	RE_acValue     = regexp.MustCompile(`^["']?(#|0x)([0-9A-Fa-f]{6})["']?`)
+	acANSI         = []string{"black", "red", "green", "yellow", "blue", "magenta", "cyan", "white"}
+	acANSISections = []string{"colors.normal", "colors.bright"}
+)
+
+// alacrittyColors holds the color settings of an Alacritty config, keyed by
+// their dotted path.
+type alacrittyColors struct {
+	values  map[string]string
+	indexed map[int]string
+}
+
+// Values that aren't colors are kept as they are, to fail when they're
+// read as one.
+func (ac *alacrittyColors) set(path, val string) {
+	if match := RE_acValue.FindStringSubmatch(val); len(match) > 1 {
+		ac.values[path] = "#" + match[2]
+	} else if fields := strings.Fields(val); len(fields) > 0 {
+		ac.values[path] = strings.Trim(fields[0], `"'`)
+	}
+}
+
+// acEntry is an indexed_colors entry being read; its keys come in any order.
+type acEntry struct {
+	index int
+	color string
+}
+
+// indexed_colors entries may be inline tables or spread over lines.
+func (ac *alacrittyColors) scanIndexed(line string, entry *acEntry) {
+	if match := RE_acIndex.FindStringSubmatch(line); len(match) > 1 {
+		entry.index, _ = strconv.Atoi(match[1])
+	}
+	if match := RE_acColor.FindStringSubmatch(line); len(match) > 1 {
+		entry.color = "#" + match[2]
+	}
+	if entry.index >= 0 && entry.color != "" {
+		ac.indexed[entry.index] = entry.color
+		*entry = acEntry{index: -1}
+	}
+}
+
+func scanAlacrittyTOML(file []byte) *alacrittyColors {
+	ac := &alacrittyColors{make(map[string]string), make(map[int]string)}
+	section, entry := "", acEntry{index: -1}
+
+	scan := bufio.NewScanner(bytes.NewReader(file))
+	for scan.Scan() {
+		line := strings.TrimSpace(scan.Text())
+		if match := RE_tomlHeader.FindStringSubmatch(line); len(match) > 1 {
+			section, entry = match[1], acEntry{index: -1}
+		} else if section == "colors.indexed_colors" || RE_acIndex.MatchString(line) {
+			ac.scanIndexed(line, &entry)
+		} else if match := RE_tomlAssn.FindStringSubmatch(line); len(match) > 1 {
+			ac.set(section+"."+match[1], match[2])
+		}
+	}
+	return ac
+}
+
+func scanAlacrittyYAML(file []byte) *alacrittyColors {
+	ac := &alacrittyColors{make(map[string]string), make(map[int]string)}
+	// enclosing keys and their indentation
+	var path []string
+	var indents []int
+	entry := acEntry{index: -1}
+
+	scan := bufio.NewScanner(bytes.NewReader(file))
+	for scan.Scan() {
+		line := scan.Text()
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		if len(path) > 0 && path[len(path)-1] == "indexed_colors" {
+			ac.scanIndexed(line, &entry)
+		}
+
+		match := RE_yamlLine.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		indent := len(match[1])
+		for len(indents) > 0 && indent <= indents[len(indents)-1] {
+			path, indents = path[:len(path)-1], indents[:len(indents)-1]
+		}
+		if match[3] == "" {
+			path, indents = append(path, match[2]), append(indents, indent)
+		} else {
+			ac.set(strings.Join(append(path, match[2]), "."), match[3])
+		}
+	}
+	return ac
+}
+
+func (ac *alacrittyColors) Theme() (Theme, error) {
+	palette := make(map[int]string)
+	for i, section := range acANSISections {
+		for j, name := range acANSI {
+			if spec, found := ac.values[section+"."+name]; found {
+				palette[i*8+j] = spec
+			}
+		}
+	}
+	for index, spec := range ac.indexed {
+		if index >= 16 {
+			palette[index] = spec
+		}
+	}
+	return paletteColors(palette,
+		ac.values["colors.primary.foreground"],
+		ac.values["colors.primary.background"])
+}
+
+func readAlacritty(file []byte) (Theme, error) {
+	return scanAlacrittyTOML(file).Theme()
+}
+
+func readAlacrittyYAML(file []byte) (Theme, error) {
+	return scanAlacrittyYAML(file).Theme()
+}
+
+func acName(c tcell.Color) string {
+	return strings.TrimPrefix(cNames[c].string, "bright-")
+}
+
+func writeAlacritty(w io.Writer, m Meta, t Theme) error {
+	var b strings.Builder
+	slots := t.Slots()
+	if len(slots) < 16 {
+		return fmt.Errorf("theme has only %d colors", len(slots))
+	}
+
+	fmt.Fprintf(&b, "# %s\n\n", m.header())
+	fmt.Fprintf(&b, "[colors.primary]\n")
+	fmt.Fprintf(&b, "background = \"%s\"\n", hexColor(t[Background].RGBA))
+	fmt.Fprintf(&b, "foreground = \"%s\"\n", hexColor(t[Foreground].RGBA))
+
+	for i, section := range acANSISections {
+		fmt.Fprintf(&b, "\n[%s]\n", section)
+		for _, c := range slots[i*8 : i*8+8] {
+			fmt.Fprintf(&b, "%s = \"%s\"\n", acName(c), hexColor(t[c].RGBA))
+		}
+	}
+	for i, c := range slots[16:] {
+		fmt.Fprintf(&b, "\n[[colors.indexed_colors]]\n")
+		fmt.Fprintf(&b, "index = %d\ncolor = \"%s\"\n", 16+i, hexColor(t[c].RGBA))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeAlacrittyYAML writes the configuration format used before Alacritty
+// 0.13.
+func writeAlacrittyYAML(w io.Writer, m Meta, t Theme) error {
+	var b strings.Builder
+	slots := t.Slots()
+	if len(slots) < 16 {
+		return fmt.Errorf("theme has only %d colors", len(slots))
+	}
+
+	fmt.Fprintf(&b, "# %s\n\n", m.header())
+	fmt.Fprintf(&b, "colors:\n  primary:\n")
+	fmt.Fprintf(&b, "    background: '%s'\n", hexColor(t[Background].RGBA))
+	fmt.Fprintf(&b, "    foreground: '%s'\n", hexColor(t[Foreground].RGBA))
+
+	for i, section := range acANSISections {
+		fmt.Fprintf(&b, "  %s:\n", strings.TrimPrefix(section, "colors."))
+		for _, c := range slots[i*8 : i*8+8] {
+			fmt.Fprintf(&b, "    %s: '%s'\n", acName(c), hexColor(t[c].RGBA))
+		}
+	}
+	if len(slots) > 16 {
+		fmt.Fprintf(&b, "  indexed_colors:\n")
+	}
+	for i, c := range slots[16:] {
+		fmt.Fprintf(&b, "    - { index: %d, color: '%s' }\n", 16+i, hexColor(t[c].RGBA))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
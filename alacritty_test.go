@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"github.com/gdamore/tcell/v2"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+const alacrittyTOML = `
+# alacritty.toml, as of 0.13
+[colors.primary]
+background = '0x181410'
+foreground = "#d0d0c8" # comment
+
+[colors.cursor]
+text = "CellBackground"
+cursor = "#ff0000"
+
+[colors.normal]
+black = "#000000"
+red = "#100000"
+green = "#001000"
+yellow = "#101000"
+blue = "#000010"
+magenta = "#100010"
+cyan = "#001010"
+white = "#101010"
+
+[colors.bright]
+black = "#808080"
+red = "#ff0000"
+green = "#00ff00"
+yellow = "#ffff00"
+blue = "#0000ff"
+magenta = "#ff00ff"
+cyan = "#00ffff"
+white = "#ffffff"
+
+[[colors.indexed_colors]]
+index = 16
+color = "#010203"
+
+[[colors.indexed_colors]]
+color = "0x040506"
+index = 17
+`
+
+const alacrittyYAML = `
+# alacritty.yml, before 0.13
+font:
+  size: 11
+colors:
+  # Default colors
+  primary:
+    background: '0x181410'
+    foreground: "#d0d0c8"
+  cursor:
+    text: CellBackground
+  normal:
+    black:   '#000000'
+    red:     '#100000'
+    green:   '#001000'
+    yellow:  '#101000'
+    blue:    '#000010'
+    magenta: '#100010'
+    cyan:    '#001010'
+    white:   '#101010'
+  bright:
+    black:   '#808080'
+    red:     '#ff0000'
+    green:   '#00ff00'
+    yellow:  '#ffff00'
+    blue:    '#0000ff'
+    magenta: '#ff00ff'
+    cyan:    '#00ffff'
+    white:   '#ffffff'
+  indexed_colors:
+    - { index: 16, color: '#010203' }
+    - index: 17
+      color: '0x040506'
+window:
+  opacity: 1.0
+`
+
+func checkAlacritty(t *testing.T, theme Theme) {
+	t.Helper()
+	for c, want := range map[tcell.Color]color.RGBA{
+		Background:   {0x18, 0x14, 0x10, 0xff},
+		Foreground:   {0xd0, 0xd0, 0xc8, 0xff},
+		Red:          {0x10, 0, 0, 0xff},
+		BrightYellow: {0xff, 0xff, 0, 0xff},
+		Black + 16:   {1, 2, 3, 0xff},
+		Black + 17:   {4, 5, 6, 0xff},
+	} {
+		if got := theme[c].RGBA; got != want {
+			t.Errorf("%s: got %v, want %v", slotName(c), got, want)
+		}
+	}
+	if n := len(theme.Slots()); n != 18 {
+		t.Errorf("%d palette slots, want 18", n)
+	}
+}
+
+func TestAlacrittyTOML(t *testing.T) {
+	theme, err := readAlacritty([]byte(alacrittyTOML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkAlacritty(t, theme)
+
+	// indexed colors as an inline array
+	inline := strings.Replace(alacrittyTOML, "[[colors.indexed_colors]]\nindex = 16\ncolor = \"#010203\"",
+		"[colors]\nindexed_colors = [{ index = 16, color = \"#010203\" }]", 1)
+	if theme, err = readAlacritty([]byte(inline)); err != nil {
+		t.Fatal(err)
+	}
+	checkAlacritty(t, theme)
+}
+
+func TestAlacrittyYAML(t *testing.T) {
+	theme, err := readAlacrittyYAML([]byte(alacrittyYAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkAlacritty(t, theme)
+}
+
+func TestAlacrittyRoundTrip(t *testing.T) {
+	for _, fname := range []string{"alacritty", "alacritty-yaml"} {
+		roundTrip(t, fname, sampleTheme())
+	}
+
+	short := sampleTheme()
+	for c := Black + 15; c < Black+18; c++ {
+		delete(short, c)
+	}
+	for _, write := range []func(*bytes.Buffer) error{
+		func(b *bytes.Buffer) error { return writeAlacritty(b, Meta{Name: "short"}, short) },
+		func(b *bytes.Buffer) error { return writeAlacrittyYAML(b, Meta{Name: "short"}, short) },
+	} {
+		if err := write(new(bytes.Buffer)); err == nil {
+			t.Error("wrote a theme with 15 colors")
+		}
+	}
+}
+
+func TestAlacrittyMalformed(t *testing.T) {
+	for _, file := range []string{
+		"",
+		strings.Replace(alacrittyTOML, "[colors.bright]", "[colors.dim]", 1),
+		strings.Replace(alacrittyTOML, `red = "#ff0000"`, `red = "#ff00"`, 1),
+		strings.Replace(alacrittyTOML, `foreground = "#d0d0c8"`, `foreground = "d0d0c8"`, 1),
+	} {
+		if _, err := readAlacritty([]byte(file)); err == nil {
+			t.Errorf("no error reading %q", file)
+		}
+	}
+	for _, file := range []string{
+		"colors:\n  primary:\n    background: '#000000'\n",
+		strings.Replace(alacrittyYAML, "  normal:", "  dim:", 1),
+		strings.Replace(alacrittyYAML, "background: '0x181410'", "background: 'black'", 1),
+	} {
+		if _, err := readAlacrittyYAML([]byte(file)); err == nil {
+			t.Errorf("no error reading %q", file)
+		}
+	}
+}
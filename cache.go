@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// CACHE_VERSION changes whenever cacheEntry does, so an older index is
+// dropped rather than misread.
+const CACHE_VERSION = 3
+
+// cacheEntry is a parsed theme, valid as long as its file's modification
+// time and size haven't changed.
+type cacheEntry struct {
+	ModTime time.Time `json:"mtime"`
+	Size    int64     `json:"size"`
+	Meta    Meta      `json:"meta"`
+	Colors  Theme     `json:"colors"`
+}
+
+// themeCache is the index of parsed themes, keyed by path. Themes that
+// failed to load aren't cached, so their errors come back every time.
+type themeCache struct {
+	sync.Mutex
+	entries map[string]*cacheEntry
+}
+
+type cacheFile struct {
+	Version int                    `json:"version"`
+	Entries map[string]*cacheEntry `json:"entries"`
+}
+
+func cachePath() string {
+	return filepath.Join(xdgDir("XDG_CACHE_HOME", "$HOME/.cache"), "cx", "index.json")
+}
+
+func newCache() *themeCache {
+	return &themeCache{entries: make(map[string]*cacheEntry)}
+}
+
+// readCache starts over with an empty cache if there's any trouble reading
+// it.
+func readCache() *themeCache {
+	c := newCache()
+	file, err := os.ReadFile(cachePath())
+	if err != nil {
+		return c
+	}
+	var cf cacheFile
+	if err := json.Unmarshal(file, &cf); err != nil || cf.Version != CACHE_VERSION {
+		return c
+	}
+	if cf.Entries != nil {
+		c.entries = cf.Entries
+	}
+	return c
+}
+
+// cacheable files have a modification time: not the builtin ones.
+func cacheable(tf *themeFile) bool {
+	return tf.info != nil && !tf.info.ModTime().IsZero()
+}
+
+func (c *themeCache) lookup(tf *themeFile) (*ThemeEntry, bool) {
+	if !cacheable(tf) {
+		return nil, false
+	}
+	c.Lock()
+	defer c.Unlock()
+
+	ce, found := c.entries[tf.path]
+	if !found || ce.Size != tf.info.Size() || !ce.ModTime.Equal(tf.info.ModTime()) {
+		return nil, false
+	}
+	return newEntry(tf.name, ce.Colors, ce.Meta, tf.path, tf.f.name, nil), true
+}
+
+func (c *themeCache) store(tf *themeFile, e *ThemeEntry) {
+	if !cacheable(tf) || e.err != nil {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	c.entries[tf.path] = &cacheEntry{tf.info.ModTime(), tf.info.Size(), e.meta, e.Theme}
+}
+
+func (c *themeCache) write() error {
+	c.Lock()
+	defer c.Unlock()
+	return writeFile(cachePath(), func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(&cacheFile{CACHE_VERSION, c.entries})
+	})
+}
@@ -4,7 +4,7 @@ import (
 	"fmt"
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
-	//"log"
+	"log"
 	"strings"
 )
 
@@ -139,13 +139,14 @@ func (tw *CtlTweaker) drawGrid(screen tcell.Screen) (int, int, int, int) {
 		}
 
 		if i%2 == 0 && tw.HasFocus() {
+			max := tw.prop.Max()
 			xpos := x + x_off - 1
-			label := fmt.Sprintf("%d", i*32)
+			label := fmt.Sprintf("%d", i*(max+1)/8)
 			switch i {
 			case 0, 2:
 				xpos += 1
 			case 8:
-				label = "255"
+				label = fmt.Sprintf("%d", max)
 				xpos -= 1
 			}
 			tview.PrintSimple(screen, "[::d]"+label, xpos, y_mid+1)
@@ -172,9 +173,12 @@ func (tw *CtlTweaker) Draw(screen tcell.Screen) {
 		b := theme[color]
 		v := b.Access(tw.prop)
 
-		off := int(v) * w / 255
-		if off == w {
-			off--
+		// out-of-gamut colors can sit past either end of the scale
+		off := v * w / tw.prop.Max()
+		if off < 0 {
+			off = 0
+		} else if off >= w {
+			off = w - 1
 		}
 		// attempt to spread out collisions into adjacent cells
 		for i := 0; i <= 3; i++ {
@@ -302,6 +306,15 @@ func (cs *CtlSelector) Reset() *CtlSelector {
 	return cs
 }
 
+// all is every color of the theme.
+func (cs *CtlSelector) all() CMask {
+	var mask CMask
+	for _, mod := range cs.model {
+		mask |= CMask(0).Mask(mod.Color)
+	}
+	return mask
+}
+
 func (cs *CtlSelector) Toggle(mask CMask) *CtlSelector {
 	if cs.mask&mask == mask {
 		cs.mask ^= mask
@@ -327,6 +340,12 @@ func (cs *CtlSelector) InputHandler() func(event *tcell.EventKey, setFocus func(
 				cs.Pick()
 			case 'c':
 				cs.Toggle(CMask(0).Colors())
+			case 'n':
+				cs.Toggle(CMask(0)._Colors())
+			case 'b':
+				cs.Toggle(CMask(0).BrightColors())
+			case 'x':
+				cs.Toggle(CMask(0).Extras() & cs.all())
 			}
 			return
 		}
@@ -385,33 +404,347 @@ func (cs *CtlSelector) Draw(screen tcell.Screen) {
 	}
 }
 
+// CtlContrast shows how each selected color, and the foreground, reads on
+// the background. It takes no input.
+type CtlContrast struct {
+	*Control
+}
+
+func (cc *CtlContrast) Init(C *Console) *CtlContrast {
+	cc.Control = new(Control).
+		Init(C, "contrast").
+		SetBlurTitle("[ contrast ]", 0).
+		SetBlurBorder(true).
+		SetBorderPadding(0, 0, 1, 1)
+	return cc
+}
+
+// Entries flow across the panel: the color's name in the color itself, then
+// its WCAG ratio and APCA Lc, reversed when either is below the minimum.
+func (cc *CtlContrast) Draw(screen tcell.Screen) {
+	cc.DrawForSubclass(screen, cc)
+	x, y, w, h := cc.GetInnerRect()
+	theme := cc.C.Theme()
+
+	colors := []tcell.Color{Foreground}
+	for _, c := range cc.C.Selection() {
+		if c != Foreground && c != Background {
+			colors = append(colors, c)
+		}
+	}
+
+	const ewidth = 15
+	cols := w / ewidth
+	if cols == 0 {
+		return
+	}
+	for i, c := range colors {
+		row, col := i/cols, i%cols
+		if row >= h {
+			break
+		}
+		name, found := cNames[c]
+		if !found {
+			name.abbr = fmt.Sprintf("%v", CMask(0).Index(c))
+		}
+
+		ex, ey := x+col*ewidth, y+row
+		st_n := tcell.StyleDefault.Foreground(c).Bold(true)
+		st_v := tcell.StyleDefault.Dim(true)
+		if !theme.Readable(c, Background) {
+			st_v = tcell.StyleDefault.Reverse(true).Bold(true)
+		}
+		for i, ru := range fmt.Sprintf("%-3s", name.abbr) {
+			screen.SetContent(ex+i, ey, ru, nil, st_n)
+		}
+		val := fmt.Sprintf("%5.2f %4.0f", theme.Contrast(c, Background), theme.APCA(c, Background))
+		for i, ru := range val {
+			screen.SetContent(ex+3+i, ey, ru, nil, st_v)
+		}
+	}
+}
+
+// CtlPlane plots the selection on the Cb×Cr plane, or OKLab's a×b, with
+// neutral in the middle. One color at a time is picked, and can be grabbed
+// and moved around the plane.
+type CtlPlane struct {
+	*Control
+	lab  bool
+	pick tcell.Color
+	grab bool
+}
+
+// grabber is a control that, while grabbing, takes even the keys Console
+// moves focus with.
+type grabber interface {
+	Grabbing() bool
+}
+
+func (cp *CtlPlane) Init(C *Console) *CtlPlane {
+	cp.Control = new(Control).
+		Init(C, "chroma plane").
+		SetBorderPadding(0, 0, 1, 1)
+	return cp
+}
+
+// props are the properties along x and y.
+func (cp *CtlPlane) props() (cprop8, cprop8) {
+	if cp.lab {
+		return OkA, OkB
+	}
+	return Cb, Cr
+}
+
+func (cp *CtlPlane) Grabbing() bool {
+	return cp.grab
+}
+
+// picked is the picked color, which falls back to the first selected one
+// when the selection has changed under it.
+func (cp *CtlPlane) picked() (tcell.Color, bool) {
+	sel := cp.C.Selection()
+	if len(sel) == 0 {
+		return 0, false
+	}
+	for _, c := range sel {
+		if c == cp.pick {
+			return c, true
+		}
+	}
+	cp.pick = sel[0]
+	return cp.pick, true
+}
+
+func (cp *CtlPlane) step(dir int) *CtlPlane {
+	sel := cp.C.Selection()
+	cur, ok := cp.picked()
+	if !ok {
+		return cp
+	}
+	for i, c := range sel {
+		if c == cur {
+			cp.pick = sel[(i+dir+len(sel))%len(sel)]
+			break
+		}
+	}
+	return cp
+}
+
+func (cp *CtlPlane) Draw(screen tcell.Screen) {
+	xp, yp := cp.props()
+	cp.title = fmt.Sprintf("%s × %s", xp.Abbr(), yp.Abbr())
+	cur, ok := cp.picked()
+	if ok && cp.grab {
+		cp.title += ": moving " + slotName(cur)
+	}
+	cp.SetBlurTitle("[ "+cp.title+" ]", 0)
+	cp.DrawForSubclass(screen, cp)
+
+	x, y, w, h := cp.GetInnerRect()
+	if w < 3 || h < 3 {
+		return
+	}
+	at := func(v, max, size int) int {
+		off := v * (size - 1) / max
+		if off < 0 {
+			off = 0
+		} else if off >= size {
+			off = size - 1
+		}
+		return off
+	}
+
+	// axes through neutral
+	st_axis := tcell.StyleDefault.Dim(true)
+	x_mid, y_mid := x+(w-1)/2, y+(h-1)/2
+	for i := 0; i < w; i++ {
+		screen.SetContent(x+i, y_mid, tview.BoxDrawingsLightHorizontal, nil, st_axis)
+	}
+	for j := 0; j < h; j++ {
+		screen.SetContent(x_mid, y+j, tview.BoxDrawingsLightVertical, nil, st_axis)
+	}
+	screen.SetContent(x_mid, y_mid, tview.BoxDrawingsLightVerticalAndHorizontal, nil, st_axis)
+
+	theme := cp.C.Theme()
+	plot := func(c tcell.Color, picked bool) {
+		b := theme[c]
+		px := x + at(b.Access(xp), xp.Max(), w)
+		py := y + h - 1 - at(b.Access(yp), yp.Max(), h)
+
+		ch, st := '●', tcell.StyleDefault.Foreground(c).Dim(!cp.HasFocus())
+		// background color requires special handling
+		if b.RGBA == theme[Background].RGBA {
+			ch, st = '▒', st.Foreground(tcell.ColorDefault)
+		}
+		if picked && cp.HasFocus() {
+			st = st.Reverse(true)
+			if cp.grab {
+				ch = '◆'
+			}
+		}
+		screen.SetContent(px, py, ch, nil, st)
+	}
+	for _, c := range cp.C.Selection() {
+		if c != cur {
+			plot(c, false)
+		}
+	}
+	if ok {
+		plot(cur, true)
+	}
+}
+
+// h and l pick the previous and next color, enter or space grab it, and
+// p or P switches planes. While grabbing, h, j, k, l and the arrows move the
+// color, three times as far with shift or without alt, as in CtlTweaker;
+// enter, space or esc let go.
+func (cp *CtlPlane) InputHandler() func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
+	return cp.WrapInputHandler(func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
+		mod := event.Modifiers()
+		key := event.Key()
+
+		if key == tcell.KeyRune {
+			rune := event.Rune()
+			if rune < 'a' && rune >= 'A' {
+				mod |= tcell.ModShift
+			}
+			switch rune {
+			case 'H', 'h':
+				key = tcell.KeyLeft
+			case 'L', 'l':
+				key = tcell.KeyRight
+			case 'J', 'j':
+				key = tcell.KeyDown
+			case 'K', 'k':
+				key = tcell.KeyUp
+			case ' ':
+				key = tcell.KeyEnter
+			case 'p', 'P':
+				cp.lab = !cp.lab
+				return
+			}
+		}
+
+		if !cp.grab {
+			switch key {
+			case tcell.KeyLeft:
+				cp.step(-1)
+			case tcell.KeyRight:
+				cp.step(1)
+			case tcell.KeyEnter:
+				_, cp.grab = cp.picked()
+			}
+			return
+		}
+
+		xp, yp := cp.props()
+		prop, adj := xp, 0
+		switch key {
+		case tcell.KeyLeft:
+			adj = -1
+		case tcell.KeyRight:
+			adj = 1
+		case tcell.KeyDown:
+			prop, adj = yp, -1
+		case tcell.KeyUp:
+			prop, adj = yp, 1
+		case tcell.KeyEnter, tcell.KeyEsc:
+			cp.grab = false
+			return
+		}
+		if adj == 0 {
+			return
+		}
+		if mod&tcell.ModAlt == 0 {
+			adj *= 3
+		}
+		if mod&tcell.ModShift != 0 {
+			adj *= 3
+		}
+		if cur, ok := cp.picked(); ok {
+			cp.C.AdjustOne(cur, prop, adj)
+		}
+	})
+}
+
 type Console struct {
 	*tview.Flex
 	csel    *CtlSelector
+	ccon    *CtlContrast
+	plane   *CtlPlane
+	name    string
+	meta    Meta
 	theme   Theme
 	reset   Theme
+	rebase  Theme
+	saved   Theme
+	page    int
 	focus_i int
 }
 
-func (C *Console) Init(t Theme) *Console {
+// saved themes get their own name so the original is never overwritten
+const SAVE_SUFFIX = "-cx"
+
+// The tweakers come in pages, one color model to a page.
+var tweakPages = [][]cprop8{
+	{Y, Cb, Cr, R, G, B},
+	{Y, CbCrH, CbCrC},
+	{OkL, OkA, OkB},
+	{OkL, OkC, OkH},
+	{Hue, HslS, HslL},
+	{Hue, HsvS, HsvV},
+}
+
+func (C *Console) Init(name string, e *ThemeEntry) *Console {
 	C.Flex = tview.NewFlex().
 		SetDirection(tview.FlexRow)
 
-	C.reset = t
+	C.name = name
+	C.meta = e.meta
+	C.reset = e.Theme
 	C.Reset()
 
 	C.csel = new(CtlSelector).Init(C)
-	C.AddItem(C.csel, 0, 1, true)
+	C.ccon = new(CtlContrast).Init(C)
+	C.plane = new(CtlPlane).Init(C)
+	return C.SetPage(0)
+}
+
+// SetPage shows a page of tweakers, or after the last of them the chroma
+// plane, wrapping around at either end.
+func (C *Console) SetPage(page int) *Console {
+	n := len(tweakPages) + 1
+	C.page = (page%n + n) % n
 
-	for _, p := range []cprop8{Y, Cb, Cr, R, G, B} {
-		C.AddItem(new(CtlTweaker).Init(C, p), 0, 1, true)
+	C.Clear()
+	C.AddItem(C.csel, 0, 1, true)
+	if C.page < len(tweakPages) {
+		for _, p := range tweakPages[C.page] {
+			C.AddItem(new(CtlTweaker).Init(C, p), 0, 1, true)
+		}
+	} else {
+		C.AddItem(C.plane, 0, 4, true)
+	}
+	C.AddItem(C.ccon, 0, 1, false)
+	if C.focus_i >= C.controls() {
+		C.focus_i = C.controls() - 1
 	}
 	return C
 }
 
+// controls counts the items that take focus: all but the contrast panel.
+func (C *Console) controls() int {
+	return C.GetItemCount() - 1
+}
+
 func (C *Console) Adjust(prop cprop8, adj int) Theme {
 	return C.theme.Adjust(C.csel.mask, prop, adj).Apply()
 }
+
+// AdjustOne adjusts a single color, selected or not.
+func (C *Console) AdjustOne(c tcell.Color, prop cprop8, adj int) Theme {
+	return C.theme.Adjust(CMask(0).Mask(c), prop, adj).Apply()
+}
 func (C *Console) Selection() []tcell.Color {
 	return C.csel.mask.Iter()
 }
@@ -423,9 +756,116 @@ func (C *Console) Reset() *Console {
 	return C
 }
 
+// Offer keeps a new version of the theme being edited, for Rebase. Our own
+// saves come back this way too, and aren't offered.
+func (C *Console) Offer(t Theme) {
+	if t.Equal(C.theme) || t.Equal(C.saved) {
+		return
+	}
+	if len(t) != len(C.reset) {
+		log.Println(C.name, "changed on disk, but its palette size changed: can't rebase")
+		return
+	}
+	C.rebase = t
+	log.Println(C.name, "changed on disk: ctrl-b to rebase edits onto it")
+}
+
+// Rebase moves the edits onto the offered version of the theme, which
+// becomes the one ctrl-r resets to.
+func (C *Console) Rebase() *Console {
+	if C.rebase == nil {
+		return C
+	}
+	C.theme = C.theme.Rebase(C.reset, C.rebase)
+	C.reset, C.rebase = C.rebase, nil
+	log.Println("rebased onto", C.name)
+	return C
+}
+
+// FixContrast makes the selected colors readable on the background, and
+// says which it couldn't.
+func (C *Console) FixContrast() *Console {
+	failed := C.theme.FixContrast(C.csel.mask)
+	if len(failed) == 0 {
+		log.Println("selection is readable")
+		return C
+	}
+	var names []string
+	for _, c := range failed {
+		names = append(names, slotName(c))
+	}
+	log.Println("couldn't make readable:", strings.Join(names, ", "))
+	return C
+}
+
+func (C *Console) saveName() string {
+	if strings.HasSuffix(C.name, SAVE_SUFFIX) {
+		return C.name
+	}
+	return C.name + SAVE_SUFFIX
+}
+
+// editMeta describes the edited theme: the original, marked as edited, and
+// with its variant worked out again in case the edits changed it.
+func (C *Console) editMeta() Meta {
+	m := C.meta
+	if !strings.HasSuffix(m.Name, SAVE_SUFFIX) {
+		m.Name += SAVE_SUFFIX
+	}
+	m.Variant = C.theme.Variant()
+	return m
+}
+
+// Save stores the edited theme in the native format.
+func (C *Console) Save() {
+	m := C.editMeta()
+	path, err := SaveJSON(C.saveName(), &ThemeFile{
+		Name:    m.Name,
+		Author:  m.Author,
+		Parent:  strings.TrimSuffix(C.name, SAVE_SUFFIX),
+		Variant: m.Variant,
+		System:  m.System,
+		Cursor:  m.Cursor,
+		Colors:  C.theme,
+	})
+	if err != nil {
+		log.Println("couldn't save theme", err)
+		return
+	}
+	C.saved = C.theme.Copy()
+	log.Println("saved", path)
+}
+
+// Export writes the edited theme in each of EXPORT_FORMATS, and renders it
+// into each of TEMPLATE_DIRS.
+func (C *Console) Export() {
+	for _, fname := range EXPORT_FORMATS {
+		path, err := Export(fname, C.saveName(), C.editMeta(), C.theme)
+		if err != nil {
+			log.Println("couldn't export theme", err)
+			continue
+		}
+		log.Println("exported", path)
+	}
+	for _, dir := range TEMPLATE_DIRS {
+		paths, err := RenderTemplates(dir, C.saveName(), C.editMeta(), C.theme)
+		for _, path := range paths {
+			log.Println("rendered", path)
+		}
+		if err != nil {
+			log.Println("couldn't render templates", err)
+		}
+	}
+}
+
 func (C *Console) InputHandler() func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
 	return C.WrapInputHandler(func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
 
+		if g, ok := C.GetItem(C.focus_i).(grabber); ok && g.Grabbing() {
+			C.GetItem(C.focus_i).InputHandler()(event, setFocus)
+			return
+		}
+
 		f_i := C.focus_i
 		key := event.Key()
 
@@ -435,6 +875,10 @@ func (C *Console) InputHandler() func(event *tcell.EventKey, setFocus func(p tvi
 				key = tcell.KeyDown
 			case 'K', 'k':
 				key = tcell.KeyUp
+			case ']':
+				key = tcell.KeyPgDn
+			case '[':
+				key = tcell.KeyPgUp
 			}
 		}
 
@@ -443,18 +887,43 @@ func (C *Console) InputHandler() func(event *tcell.EventKey, setFocus func(p tvi
 			f_i++
 		case tcell.KeyBacktab, tcell.KeyCtrlK, tcell.KeyUp:
 			f_i--
+		case tcell.KeyPgDn, tcell.KeyPgUp:
+			if key == tcell.KeyPgDn {
+				C.SetPage(C.page + 1)
+			} else {
+				C.SetPage(C.page - 1)
+			}
+			setFocus(C.GetItem(C.focus_i))
+			return
 		case tcell.KeyCtrlR:
 			C.Reset().Theme().Apply()
 			return
+		case tcell.KeyCtrlB:
+			C.Rebase().Theme().Apply()
+			return
+		case tcell.KeyCtrlV:
+			SIMULATE = SIMULATE.Next()
+			log.Println("simulating", SIMULATE)
+			C.Theme().Apply()
+			return
+		case tcell.KeyCtrlF:
+			C.FixContrast().Theme().Apply()
+			return
+		case tcell.KeyCtrlS:
+			C.Save()
+			return
+		case tcell.KeyCtrlE:
+			C.Export()
+			return
 		default:
 			C.GetItem(C.focus_i).InputHandler()(event, setFocus)
 			return
 		}
 
 		if f_i < 0 {
-			f_i += C.GetItemCount()
+			f_i += C.controls()
 		}
-		f_i %= C.GetItemCount()
+		f_i %= C.controls()
 		C.focus_i = f_i
 		setFocus(C.GetItem(f_i))
 	})
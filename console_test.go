@@ -0,0 +1,165 @@
+package main
+
+import (
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"image/color"
+	"os"
+	"testing"
+)
+
+// Raising Y′ on blue pushes B past 255, and lowering it on red pushes G and B
+// below 0; the tweakers must still draw their markers inside the scale.
+func TestTweakerOutOfGamut(t *testing.T) {
+	screen := tcell.NewSimulationScreen("")
+	if err := screen.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer screen.Fini()
+	screen.SetSize(80, 40)
+
+	for _, tc := range []struct {
+		c    color.RGBA
+		adj  int
+		prop cprop8
+	}{
+		{color.RGBA{0, 0, 0xff, 0xff}, 100, B},
+		{color.RGBA{0xff, 0, 0, 0xff}, -100, G},
+	} {
+		theme := sampleTheme()
+		theme[Red] = Bcolor(tc.c)
+		C := new(Console).Init("test", &ThemeEntry{Theme: theme})
+		C.theme.Adjust(C.csel.mask, Y, tc.adj)
+		if v := C.theme[Red].Access(tc.prop); v >= 0 && v <= tc.prop.Max() {
+			t.Fatalf("%v is in gamut: %s %d", tc.c, tc.prop, v)
+		}
+
+		C.SetRect(0, 0, 80, 40)
+		C.Draw(screen)
+	}
+}
+
+// Saving comes back through the watcher, and mustn't be offered for a rebase
+// that would apply the edits twice.
+func TestOfferOwnSave(t *testing.T) {
+	isolate(t)
+	C := new(Console).Init("foo-cx", &ThemeEntry{Theme: sampleTheme()})
+	C.theme.Adjust(C.csel.mask, R, 10)
+	C.Save()
+	saved := C.theme.Copy()
+
+	C.Offer(saved)
+	C.theme.Adjust(C.csel.mask, R, 10)
+	C.Offer(saved)
+	if C.rebase != nil {
+		t.Fatal("offered our own save")
+	}
+
+	changed := sampleTheme()
+	changed[Red] = Bcolor(color.RGBA{0x20, 0x40, 0x80, 0xff})
+	C.Offer(changed)
+	if C.rebase == nil {
+		t.Fatal("didn't offer a change on disk")
+	}
+}
+
+// x toggles the slots past the ANSI colors that the theme has.
+func TestSelectExtras(t *testing.T) {
+	C := new(Console).Init("test", &ThemeEntry{Theme: sampleTheme()})
+	press := func(r rune) {
+		C.csel.InputHandler()(tcell.NewEventKey(tcell.KeyRune, r, 0), func(tview.Primitive) {})
+	}
+
+	press('x')
+	want := CMask(0).Colors() | CMask(0).Mask(Black+16) | CMask(0).Mask(Black+17)
+	if C.csel.mask != want {
+		t.Errorf("got %v, want %v", C.csel.mask.Iter(), want.Iter())
+	}
+	press('x')
+	if C.csel.mask != CMask(0).Colors() {
+		t.Errorf("got %v after toggling back", C.csel.mask.Iter())
+	}
+}
+
+// On the plane page, h and l pick colors; once one is grabbed the plane
+// takes every key, even the ones Console moves focus with.
+func TestPlaneGrab(t *testing.T) {
+	devnull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer devnull.Close()
+	stderr := os.Stderr
+	os.Stderr = devnull
+	defer func() { os.Stderr = stderr }()
+
+	C := new(Console).Init("test", &ThemeEntry{Theme: sampleTheme()})
+	key := func(k tcell.Key, r rune, mod tcell.ModMask) {
+		C.InputHandler()(tcell.NewEventKey(k, r, mod), func(tview.Primitive) {})
+	}
+	press := func(r rune) {
+		key(tcell.KeyRune, r, 0)
+	}
+	picked := func() tcell.Color {
+		c, _ := C.plane.picked()
+		return c
+	}
+
+	// the plane is the page before the first
+	key(tcell.KeyPgUp, 0, 0)
+	press('j')
+	if C.GetItem(C.focus_i) != C.plane {
+		t.Fatal("plane not focused")
+	}
+
+	// the selection starts on the chromatic colors, red to bright cyan
+	for _, step := range []struct {
+		r    rune
+		want tcell.Color
+	}{
+		{'l', Green}, {'h', Red}, {'h', BrightCyan}, {'l', Red},
+	} {
+		press(step.r)
+		if got := picked(); got != step.want {
+			t.Fatalf("%c: picked %s, want %s", step.r, slotName(got), slotName(step.want))
+		}
+	}
+	press('p')
+	if !C.plane.lab {
+		t.Error("p didn't switch to OKLab")
+	}
+	press('P')
+	if C.plane.lab {
+		t.Error("P didn't switch back")
+	}
+
+	press(' ')
+	if !C.plane.Grabbing() {
+		t.Fatal("not grabbing")
+	}
+	cr, cb := C.theme[Red].Access(Cr), C.theme[Red].Access(Cb)
+	press('j')
+	key(tcell.KeyTab, 0, 0)
+	key(tcell.KeyRune, 'l', tcell.ModAlt)
+	if C.GetItem(C.focus_i) != C.plane {
+		t.Error("focus moved while grabbing")
+	}
+	if got := C.theme[Red].Access(Cr); got != cr-3 {
+		t.Errorf("j: Cr %d, want %d", got, cr-3)
+	}
+	if got := C.theme[Red].Access(Cb); got != cb+1 {
+		t.Errorf("alt-l: Cb %d, want %d", got, cb+1)
+	}
+	if C.theme[Green] != sampleTheme()[Green] {
+		t.Error("moved a color that wasn't picked")
+	}
+
+	key(tcell.KeyEsc, 0, 0)
+	if C.plane.Grabbing() {
+		t.Fatal("esc didn't let go")
+	}
+	press('j')
+	if C.GetItem(C.focus_i) == C.plane {
+		t.Error("focus stayed on the plane after letting go")
+	}
+}
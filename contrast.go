@@ -0,0 +1,115 @@
+package main
+
+import (
+	"github.com/gdamore/tcell/v2"
+	"math"
+)
+
+// Below these, a color is flagged as hard to read on the background.
+var (
+	MIN_CONTRAST = 4.5
+	MIN_APCA     = 60.0
+)
+
+// Luminance is WCAG 2's relative luminance of the color as displayed.
+func (b bColor) Luminance() float64 {
+	return 0.2126*linearize(float64(b.R)/0xff) +
+		0.7152*linearize(float64(b.G)/0xff) +
+		0.0722*linearize(float64(b.B)/0xff)
+}
+
+// Contrast is the WCAG 2 contrast ratio of fg and bg, from 1 to 21.
+func (t Theme) Contrast(fg, bg tcell.Color) float64 {
+	return contrast(t[fg], t[bg])
+}
+
+func contrast(fg, bg bColor) float64 {
+	l1, l2 := fg.Luminance(), bg.Luminance()
+	if l1 < l2 {
+		l1, l2 = l2, l1
+	}
+	return (l1 + 0.05) / (l2 + 0.05)
+}
+
+// apcaY is the screen luminance APCA works from, with its soft clamp on
+// near-blacks.
+func (b bColor) apcaY() float64 {
+	y := 0.2126729*math.Pow(float64(b.R)/0xff, 2.4) +
+		0.7151522*math.Pow(float64(b.G)/0xff, 2.4) +
+		0.0721750*math.Pow(float64(b.B)/0xff, 2.4)
+	if y < 0.022 {
+		y += math.Pow(0.022-y, 1.414)
+	}
+	return y
+}
+
+// APCA is the lightness contrast Lc (APCA 0.0.98G) of text fg on bg. It's
+// positive for dark text on a light background and negative the other way
+// round; around 60 and above is comfortable for body text.
+func (t Theme) APCA(fg, bg tcell.Color) float64 {
+	return apca(t[fg], t[bg])
+}
+
+func apca(fg, bg bColor) float64 {
+	ytxt, ybg := fg.apcaY(), bg.apcaY()
+	if math.Abs(ybg-ytxt) < 0.0005 {
+		return 0
+	}
+
+	var lc float64
+	if ybg > ytxt {
+		s := (math.Pow(ybg, 0.56) - math.Pow(ytxt, 0.57)) * 1.14
+		if s >= 0.1 {
+			lc = s - 0.027
+		}
+	} else {
+		s := (math.Pow(ybg, 0.65) - math.Pow(ytxt, 0.62)) * 1.14
+		if s <= -0.1 {
+			lc = s + 0.027
+		}
+	}
+	return lc * 100
+}
+
+// Readable is whether fg on bg meets both MIN_CONTRAST and MIN_APCA.
+func (t Theme) Readable(fg, bg tcell.Color) bool {
+	return readable(t[fg], t[bg])
+}
+
+func readable(fg, bg bColor) bool {
+	return contrast(fg, bg) >= MIN_CONTRAST && math.Abs(apca(fg, bg)) >= MIN_APCA
+}
+
+// FixContrast makes each color in cm readable on Background by moving its
+// OKLCH lightness, keeping hue and chroma, as little as it takes. Lightness
+// moves in the steps of Adjust and stops where Adjust clamps; at equal
+// distances, away from the background wins. The colors that can't be made
+// readable are left alone and returned.
+func (t Theme) FixContrast(cm CMask) []tcell.Color {
+	var failed []tcell.Color
+	bg := t[Background]
+	away := 1
+	if bg.lab.L > 0.5 {
+		away = -1
+	}
+
+	for _, c := range cm.Iter() {
+		b := t[c]
+		if c == Background || readable(b, bg) {
+			continue
+		}
+		fixed := false
+		for n := 1; n <= OkL.Max() && !fixed; n++ {
+			for _, dir := range []int{away, -away} {
+				if try := b.Adjust(OkL, dir*n); readable(try, bg) {
+					t[c], fixed = try, true
+					break
+				}
+			}
+		}
+		if !fixed {
+			failed = append(failed, c)
+		}
+	}
+	return failed
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CVD is a color vision deficiency that Theme.Apply can simulate.
+type CVD int
+
+const (
+	CVD_NONE CVD = iota
+	CVD_PROTANOPIA
+	CVD_DEUTERANOPIA
+	CVD_TRITANOPIA
+	CVD_ACHROMATOPSIA
+)
+
+var cvdNames = []string{"none", "protanopia", "deuteranopia", "tritanopia", "achromatopsia"}
+
+// SIMULATE is applied to every theme on its way to the terminal.
+var SIMULATE = CVD_NONE
+
+// Machado, Oliveira and Fernandes (2009) at full severity, on linear RGB.
+// Achromatopsia keeps only luminance.
+var cvdMatrices = map[CVD][3][3]float64{
+	CVD_PROTANOPIA: {
+		{0.152286, 1.052583, -0.204868},
+		{0.114503, 0.786281, 0.099216},
+		{-0.003882, -0.048116, 1.051998},
+	},
+	CVD_DEUTERANOPIA: {
+		{0.367322, 0.860646, -0.227968},
+		{0.280085, 0.672501, 0.047413},
+		{-0.011820, 0.042940, 0.968881},
+	},
+	CVD_TRITANOPIA: {
+		{1.255528, -0.076749, -0.178779},
+		{-0.078411, 0.930809, 0.147602},
+		{0.004733, 0.691367, 0.303900},
+	},
+	CVD_ACHROMATOPSIA: {
+		{0.2126, 0.7152, 0.0722},
+		{0.2126, 0.7152, 0.0722},
+		{0.2126, 0.7152, 0.0722},
+	},
+}
+
+func (v CVD) String() string {
+	return cvdNames[v]
+}
+
+// Set lets a CVD be a flag.
+func (v *CVD) Set(name string) error {
+	for i, n := range cvdNames {
+		if n == name {
+			*v = CVD(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("not one of %s", strings.Join(cvdNames, ", "))
+}
+
+// Next cycles through the deficiencies, and back to none.
+func (v CVD) Next() CVD {
+	return (v + 1) % CVD(len(cvdNames))
+}
+
+// Simulate returns a copy of t as it looks with the deficiency, from the
+// colors as displayed.
+func (v CVD) Simulate(t Theme) Theme {
+	m, found := cvdMatrices[v]
+	if !found {
+		return t
+	}
+	sim := make(Theme)
+	for c, b := range t {
+		lin := [3]float64{
+			linearize(float64(b.R) / 0xff),
+			linearize(float64(b.G) / 0xff),
+			linearize(float64(b.B) / 0xff),
+		}
+		var out [3]float64
+		for i, row := range m {
+			out[i] = delinearize(clamp01(row[0]*lin[0] + row[1]*lin[1] + row[2]*lin[2]))
+		}
+		sim[c] = Bcolor(RGB{out[0], out[1], out[2]})
+	}
+	return sim
+}
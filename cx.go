@@ -1,10 +1,15 @@
 package main
 
 import (
+	"flag"
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
 	"log"
+	"os"
 	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
 )
 
 func borders() {
@@ -22,17 +27,131 @@ func borders() {
 }
 
 func main() {
+	var imports []string
+	flag.Func("import", "add a theme from `[format:]path` (repeatable)", func(arg string) error {
+		imports = append(imports, arg)
+		return nil
+	})
+	flag.Func("dir", "look for themes in `dir` before anywhere else (repeatable)", func(arg string) error {
+		THEME_DIRS = append(THEME_DIRS, arg)
+		return nil
+	})
+	flag.Func("template", "render the base16 template repository in `dir` on ctrl-e (repeatable)", func(arg string) error {
+		TEMPLATE_DIRS = append(TEMPLATE_DIRS, arg)
+		return nil
+	})
+	exports := flag.String("export", strings.Join(EXPORT_FORMATS, ","), "comma-separated `formats` written by ctrl-e")
+	flag.StringVar(&EXPORT_DIR, "o", EXPORT_DIR, "`dir`ectory for exported themes")
+	query := flag.Bool("query", true, "list the terminal's current palette as \"terminal\"")
+	flag.Float64Var(&MIN_CONTRAST, "contrast", MIN_CONTRAST, "flag colors with a WCAG contrast `ratio` below this")
+	flag.Float64Var(&MIN_APCA, "apca", MIN_APCA, "flag colors with an APCA `Lc` below this")
+	flag.Var(&SIMULATE, "cvd", "show themes as seen with `deficiency` (protanopia, deuteranopia, tritanopia or achromatopsia)")
+	keep := flag.Bool("keep", false, "keep the chosen theme on exit instead of restoring the terminal's palette")
+	flag.Parse()
+	EXPORT_FORMATS = strings.Split(*exports, ",")
+
 	borders()
-	themeMap := allThemes()
+	files := findThemes()
+	themeMap := make(ThemeMap)
+	// queried even with -query=false, since it's what restorePalette puts back
+	saved, err := QueryTerminal(len(b16Slots))
+	if err != nil {
+		log.Println("couldn't query terminal", err)
+	} else if *query {
+		themeMap["terminal"] = newEntry("terminal", saved, Meta{}, "", "osc", nil)
+	}
+	SAVED_PALETTE = saved
+	for _, arg := range imports {
+		name, theme, err := Import(arg)
+		if err != nil {
+			fatal("couldn't import theme", err)
+		}
+		themeMap[name] = theme
+	}
+	// the terminal's palette and imports take precedence over the library
+	pinned := make(map[string]bool)
+	for tname := range themeMap {
+		pinned[tname] = true
+	}
 	app := tview.NewApplication()
-	themer := NewThemer().init(themeMap)
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigs
+		app.Stop()
+	}()
+
+	tnames := make([]string, len(files))
+	for i, tf := range files {
+		tnames[i] = tf.name
+	}
+	themer := NewThemer().init(themeMap).Expect(tnames)
+	var cons *Console
+
+	// -keep keeps the theme itself, not a simulation of it
+	defer func() {
+		switch {
+		case !*keep:
+			restorePalette()
+		case SIMULATE != CVD_NONE:
+			SIMULATE = CVD_NONE
+			if cons != nil {
+				cons.Theme().Apply()
+			} else {
+				themer.Apply()
+			}
+		}
+	}()
+	defer guard()
+
+	// the library fills in while the UI is up, then is watched for changes
+	go func() {
+		defer guard()
+		loadBatches(files, func(batch ThemeMap) {
+			app.QueueUpdateDraw(func() {
+				for tname, e := range batch {
+					if !pinned[tname] {
+						themer.Add(tname, e)
+					}
+				}
+			})
+		})
+		WatchThemes(files, WATCH_INTERVAL, nil, func(tname string, e *ThemeEntry) {
+			app.QueueUpdateDraw(func() {
+				if pinned[tname] {
+					return
+				}
+				themer.Add(tname, e)
+				switch {
+				case cons != nil && cons.name == tname && e.err != nil:
+					log.Println(e.err)
+				case cons != nil && cons.name == tname:
+					cons.Offer(e.Theme)
+				case cons == nil:
+					if cur, ok := themer.GetTheme(); ok && cur == tname {
+						themer.Apply()
+					}
+				}
+			})
+		}, func(tname string) {
+			app.QueueUpdateDraw(func() {
+				if !pinned[tname] {
+					themer.Remove(tname)
+				}
+			})
+		})
+	}()
 	preview := new(Preview).Init().Start()
 	log.SetOutput(preview.log)
 	go func() {
+		defer guard()
+		// the preview can do without its sample, so this isn't fatal
 		cmd := exec.Command("bat", "-f", "--theme", "base16", "cx.go")
 		txt, err := cmd.Output()
 		if err != nil {
-			log.Fatalln("bat error", err)
+			log.Println("bat error", err)
+			return
 		}
 		preview.sample <- txt
 		app.Draw()
@@ -44,8 +163,7 @@ func main() {
 
 	themer.Done(func(tname string) {
 		preview.Stop()
-		theme := themeMap[tname]
-		cons := new(Console).Init(theme)
+		cons = new(Console).Init(tname, themeMap[tname])
 		flex.Clear().
 			AddItem(cons, 0, 1, true).
 			AddItem(preview, 0, 1, false)
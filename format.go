@@ -0,0 +1,258 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// Format is a theme file format that can be imported with -import and
+// written by Console.Export. meta is only there for formats that record
+// more than colors.
+type Format struct {
+	name  string
+	exts  []string
+	read  func(file []byte) (Theme, error)
+	meta  func(file []byte) Meta
+	write func(w io.Writer, m Meta, t Theme) error
+	file  func(name string) string
+}
+
+var (
+	EXPORT_DIR     = DataDir()
+	EXPORT_FORMATS = []string{"shell"}
+)
+
+var formats = []*Format{
+	{
+		name: "shell",
+		exts: []string{".sh"},
+		read: parseShell,
+		meta: shellMeta,
+		write: func(w io.Writer, m Meta, t Theme) error {
+			return t.WriteShell(w, m)
+		},
+		file: func(name string) string {
+			return "base16-" + name + ".sh"
+		},
+	},
+	{
+		// before json, so exported schemes aren't taken for native themes
+		name:  "windows-terminal",
+		exts:  []string{".wt.json"},
+		read:  readWT,
+		meta:  wtMeta,
+		write: writeWT,
+		file: func(name string) string {
+			return name + ".wt.json"
+		},
+	},
+	{
+		name: "json",
+		exts: []string{".json"},
+		read: func(file []byte) (Theme, error) {
+			tf, err := readJSON(file)
+			if err != nil {
+				return nil, err
+			}
+			return tf.Colors, nil
+		},
+		meta: func(file []byte) Meta {
+			tf, _ := readJSON(file)
+			return Meta{Name: tf.Name, Author: tf.Author, Variant: tf.Variant, System: tf.System, Cursor: tf.Cursor}
+		},
+		write: func(w io.Writer, m Meta, t Theme) error {
+			tf := &ThemeFile{Name: m.Name, Author: m.Author, Variant: m.Variant, System: m.System, Cursor: m.Cursor, Colors: t}
+			return tf.Write(w)
+		},
+		file: func(name string) string {
+			return name + ".json"
+		},
+	},
+	{
+		name:  "alacritty",
+		exts:  []string{".toml"},
+		read:  readAlacritty,
+		meta:  headerMeta,
+		write: writeAlacritty,
+		file: func(name string) string {
+			return name + ".toml"
+		},
+	},
+	{
+		// before yaml, so alacritty.yml isn't taken for a scheme
+		name:  "alacritty-yaml",
+		exts:  []string{"alacritty.yml", "alacritty.yaml"},
+		read:  readAlacrittyYAML,
+		meta:  headerMeta,
+		write: writeAlacrittyYAML,
+		file: func(name string) string {
+			return name + ".alacritty.yml"
+		},
+	},
+	{
+		name:  "kitty",
+		exts:  []string{".conf"},
+		read:  readKitty,
+		meta:  kittyMeta,
+		write: writeKitty,
+		file: func(name string) string {
+			return name + ".conf"
+		},
+	},
+	{
+		name:  "foot",
+		exts:  []string{".ini"},
+		read:  readFoot,
+		meta:  headerMeta,
+		write: writeFoot,
+		file: func(name string) string {
+			return name + ".ini"
+		},
+	},
+	{
+		name:  "iterm",
+		exts:  []string{".itermcolors"},
+		read:  readIterm,
+		meta:  headerMeta,
+		write: writeIterm,
+		file: func(name string) string {
+			return name + ".itermcolors"
+		},
+	},
+	{
+		name: "yaml",
+		exts: []string{".yaml", ".yml"},
+		read: func(file []byte) (Theme, error) {
+			s, err := parseSchemeYAML(file)
+			if err != nil {
+				return nil, err
+			}
+			return s.Theme()
+		},
+		meta: func(file []byte) Meta {
+			s, _ := parseSchemeYAML(file)
+			return Meta{Name: s.name, Author: s.author, Variant: s.variant, System: s.system}
+		},
+	},
+	{
+		name:  "xresources",
+		exts:  []string{"xresources", "xdefaults"},
+		read:  readXresources,
+		meta:  headerMeta,
+		write: writeXresources,
+		file: func(name string) string {
+			return name + ".Xresources"
+		},
+	},
+}
+
+// paletteColors builds a theme from color specs keyed by palette index,
+// taking the slots contiguous from 0. A foreground or background that isn't
+// given is taken from color7 or color0.
+func paletteColors(palette map[int]string, fg, bg string) (Theme, error) {
+	var t []color.Color
+	for {
+		spec, found := palette[len(t)]
+		if !found {
+			break
+		}
+		c, err := ParseXColor(spec)
+		if err != nil {
+			return nil, fmt.Errorf("color%d: %w", len(t), err)
+		}
+		t = append(t, c)
+	}
+	if len(t) < 16 {
+		return nil, fmt.Errorf("color%d missing", len(t))
+	}
+
+	special := func(key, spec string, unset color.Color) (color.Color, error) {
+		if spec == "" {
+			return unset, nil
+		}
+		c, err := ParseXColor(spec)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		return c, nil
+	}
+	fg_c, err := special("foreground", fg, t[7])
+	if err != nil {
+		return nil, err
+	}
+	bg_c, err := special("background", bg, t[0])
+	if err != nil {
+		return nil, err
+	}
+	return make(Theme).Init(t, fg_c, bg_c), nil
+}
+
+func formatByName(name string) (*Format, bool) {
+	for _, f := range formats {
+		if f.name == name {
+			return f, true
+		}
+	}
+	return nil, false
+}
+
+func formatByPath(path string) (*Format, bool) {
+	lpath := strings.ToLower(path)
+	for _, f := range formats {
+		for _, ext := range f.exts {
+			if strings.HasSuffix(lpath, ext) {
+				return f, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// importName makes a theme name from a file name by dropping its extension
+// and any base16- prefix; ~/.Xresources becomes "Xresources".
+func importName(path string) string {
+	base := filepath.Base(path)
+	name := strings.TrimSuffix(base, filepath.Ext(base))
+	if name == "" {
+		name = strings.TrimPrefix(base, ".")
+	}
+	return strings.TrimPrefix(name, "base16-")
+}
+
+// Import reads a theme from an argument of the form [format:]path. Without
+// a format, it's guessed from the file extension.
+func Import(arg string) (string, *ThemeEntry, error) {
+	path := arg
+	fname, rest, found := strings.Cut(arg, ":")
+	f, ok := formatByName(fname)
+	if found && ok {
+		path = rest
+	} else if f, ok = formatByPath(path); !ok {
+		return "", nil, fmt.Errorf("%s: unknown theme format", path)
+	}
+	if f.read == nil {
+		return "", nil, fmt.Errorf("%s: can't import %s", path, f.name)
+	}
+
+	t, meta, err := loadTheme(f, path)
+	if err != nil {
+		return "", nil, err
+	}
+	name := importName(path)
+	return name, newEntry(name, t, meta, path, f.name, nil), nil
+}
+
+// Export writes t to EXPORT_DIR in the given format, as the file for name.
+func Export(fname, name string, m Meta, t Theme) (string, error) {
+	f, ok := formatByName(fname)
+	if !ok || f.write == nil {
+		return "", fmt.Errorf("can't export %s", fname)
+	}
+	path := filepath.Join(EXPORT_DIR, f.file(name))
+	return path, writeFile(path, func(w io.Writer) error {
+		return f.write(w, m, t)
+	})
+}
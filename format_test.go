@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+// roundTrip writes theme in the named format and reads it back, returning
+// what was written.
+func roundTrip(t *testing.T, fname string, theme Theme) []byte {
+	t.Helper()
+	f, ok := formatByName(fname)
+	if !ok {
+		t.Fatalf("no format %s", fname)
+	}
+	var b bytes.Buffer
+	if err := f.write(&b, Meta{Name: "Sample", Author: "Someone", Variant: "dark"}, theme); err != nil {
+		t.Fatal(err)
+	}
+	got, err := f.read(b.Bytes())
+	if err != nil {
+		t.Fatalf("%s: %v\n%s", fname, err, b.Bytes())
+	}
+	if !sameColors(got, theme) {
+		t.Errorf("%s: colors changed in the round trip\n%s", fname, b.Bytes())
+	}
+	return b.Bytes()
+}
+
+// readError reads file in the named format, expecting it to fail.
+func readError(t *testing.T, fname, file string) {
+	t.Helper()
+	f, _ := formatByName(fname)
+	if _, err := f.read([]byte(file)); err == nil {
+		t.Errorf("%s: no error reading %q", fname, file)
+	}
+}
+
+// Export's header comment reads back; other files have none.
+func TestHeaderMeta(t *testing.T) {
+	for file, want := range map[string]Meta{
+		"! Ocean by Chris Kempson, exported by colorenv\n":     {Name: "Ocean", Author: "Chris Kempson"},
+		"<!-- Ocean, exported by colorenv -->\n":               {Name: "Ocean"},
+		"[colors]\n# Ocean by Chris, exported by colorenv\n":   {Name: "Ocean", Author: "Chris"},
+		"# Ocean by Chris Kempson\n":                           {},
+		"# , exported by colorenv\n":                           {},
+		"# Ocean by Chris, base24, exported by colorenv\n":     {Name: "Ocean", Author: "Chris", System: "base24"},
+		"# Ocean, base16, exported by colorenv\n":              {Name: "Ocean", System: "base16"},
+		"foreground = '#ffffff' # Ocean, exported by colorenv": {},
+	} {
+		if got := headerMeta([]byte(file)); got != want {
+			t.Errorf("%q: got %+v, want %+v", file, got, want)
+		}
+	}
+}
+
+// Formats that record metadata give back what was written.
+func TestMetaRoundTrip(t *testing.T) {
+	for _, f := range formats {
+		if f.meta == nil || f.write == nil {
+			continue
+		}
+		author := "Someone"
+		if f.name == "windows-terminal" {
+			// schemes only have a name
+			author = ""
+		}
+		m := f.meta(roundTrip(t, f.name, ansiTheme()))
+		if m.Name != "Sample" || m.Author != author {
+			t.Errorf("%s: got %q by %q", f.name, m.Name, m.Author)
+		}
+	}
+}
+
+// The scheme system survives every format with somewhere to put it.
+func TestSystemRoundTrip(t *testing.T) {
+	for _, f := range formats {
+		if f.meta == nil || f.write == nil || f.name == "windows-terminal" {
+			continue
+		}
+		var b bytes.Buffer
+		if err := f.write(&b, Meta{Name: "Sample", System: "base24"}, sampleTheme()); err != nil {
+			t.Fatal(err)
+		}
+		if m := f.meta(b.Bytes()); m.Name != "Sample" || m.System != "base24" {
+			t.Errorf("%s: got %q, system %q\n%s", f.name, m.Name, m.System, b.Bytes())
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"math"
+)
+
+// HSL and HSV have their hue in degrees, and saturation, lightness and value
+// from 0 to 1.
+type HSL struct {
+	H, S, L float64
+}
+
+type HSV struct {
+	H, S, V float64
+}
+
+// hueOf returns the hue of an RGB color whose largest and smallest
+// components are hi and lo. Grays get 0.
+func hueOf(r, g, b, hi, lo float64) float64 {
+	d := hi - lo
+	var h float64
+	switch {
+	case d == 0:
+		return 0
+	case hi == r:
+		h = math.Mod((g-b)/d, 6)
+	case hi == g:
+		h = (b-r)/d + 2
+	default:
+		h = (r-g)/d + 4
+	}
+	h *= 60
+	if h < 0 {
+		h += 360
+	}
+	return h
+}
+
+func toHSL(c RGB) HSL {
+	r, g, b := c.R, c.G, c.B
+	hi, lo := math.Max(r, math.Max(g, b)), math.Min(r, math.Min(g, b))
+	l := (hi + lo) / 2
+	var s float64
+	if d := hi - lo; d > 0 {
+		s = d / (1 - math.Abs(2*l-1))
+	}
+	return HSL{hueOf(r, g, b, hi, lo), s, l}
+}
+
+func toHSV(c RGB) HSV {
+	r, g, b := c.R, c.G, c.B
+	hi, lo := math.Max(r, math.Max(g, b)), math.Min(r, math.Min(g, b))
+	var s float64
+	if hi > 0 {
+		s = (hi - lo) / hi
+	}
+	return HSV{hueOf(r, g, b, hi, lo), s, hi}
+}
+
+// hueRGB builds a color from its hue, its chroma c, and m, the amount added
+// to every component.
+func hueRGB(h, c, m float64) RGB {
+	h = math.Mod(math.Mod(h, 360)+360, 360) / 60
+	x := c * (1 - math.Abs(math.Mod(h, 2)-1))
+	var r, g, b float64
+	switch int(h) {
+	case 0:
+		r, g = c, x
+	case 1:
+		r, g = x, c
+	case 2:
+		g, b = c, x
+	case 3:
+		g, b = x, c
+	case 4:
+		r, b = x, c
+	default:
+		r, b = c, x
+	}
+	return RGB{r + m, g + m, b + m}
+}
+
+func (c HSL) rgb() RGB {
+	chroma := (1 - math.Abs(2*c.L-1)) * c.S
+	return hueRGB(c.H, chroma, c.L-chroma/2)
+}
+
+func (c HSL) RGBA() (uint32, uint32, uint32, uint32) {
+	return c.rgb().RGBA()
+}
+
+func (c HSV) rgb() RGB {
+	chroma := c.V * c.S
+	return hueRGB(c.H, chroma, c.V-chroma)
+}
+
+func (c HSV) RGBA() (uint32, uint32, uint32, uint32) {
+	return c.rgb().RGBA()
+}
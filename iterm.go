@@ -0,0 +1,271 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"github.com/gdamore/tcell/v2"
+	"image/color"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// plistNode is any element of an XML property list.
+type plistNode struct {
+	XMLName xml.Name
+	Content string      `xml:",chardata"`
+	Nodes   []plistNode `xml:",any"`
+}
+
+// dict returns the key/value pairs of a <dict> node.
+func (n *plistNode) dict() map[string]*plistNode {
+	d := make(map[string]*plistNode)
+	for i := 0; i+1 < len(n.Nodes); i += 2 {
+		if n.Nodes[i].XMLName.Local == "key" {
+			d[n.Nodes[i].Content] = &n.Nodes[i+1]
+		}
+	}
+	return d
+}
+
+// itermSpaces take the components of each color space iTerm2 writes to
+// linear sRGB: the space's transfer function, then its primaries. All three
+// have a D65 white point. "Calibrated" is macOS's Generic RGB, which is also
+// what iTerm2 assumes for colors without a Color Space.
+var itermSpaces = map[string]struct {
+	linearize func(float64) float64
+	primaries [3][3]float64
+}{
+	"sRGB": {linearize, [3][3]float64{
+		{1, 0, 0},
+		{0, 1, 0},
+		{0, 0, 1},
+	}},
+	"P3": {linearize, [3][3]float64{
+		{1.2249402, -0.2249402, 0},
+		{-0.0420570, 1.0420570, 0},
+		{-0.0196376, -0.0786360, 1.0982736},
+	}},
+	"Calibrated": {func(c float64) float64 {
+		return math.Copysign(math.Pow(math.Abs(c), 1.8), c)
+	}, [3][3]float64{
+		{1.0252525, -0.0265475, 0.0012951},
+		{0.0193935, 0.9480280, 0.0325785},
+		{-0.0017695, -0.0014423, 1.0032119},
+	}},
+}
+
+func itermColor(n *plistNode) (color.RGBA, error) {
+	d := n.dict()
+	name := "Calibrated"
+	if val, found := d["Color Space"]; found {
+		name = strings.TrimSpace(val.Content)
+	}
+	space, found := itermSpaces[name]
+	if !found {
+		return color.RGBA{}, &ParseError{Err: fmt.Errorf("unknown color space %q", name)}
+	}
+
+	var lin [3]float64
+	for i, key := range []string{"Red Component", "Green Component", "Blue Component"} {
+		val, found := d[key]
+		if !found {
+			return color.RGBA{}, fmt.Errorf("missing %s", key)
+		}
+		f, err := strconv.ParseFloat(strings.TrimSpace(val.Content), 64)
+		if err != nil {
+			return color.RGBA{}, err
+		}
+		lin[i] = space.linearize(f)
+	}
+
+	var comp [3]uint8
+	for i, row := range space.primaries {
+		c := delinearize(row[0]*lin[0] + row[1]*lin[1] + row[2]*lin[2])
+		comp[i] = uint8(math.Round(clamp01(c) * 0xff))
+	}
+	return color.RGBA{comp[0], comp[1], comp[2], 0xff}, nil
+}
+
+// readIterm reads an iTerm2 .itermcolors property list, converting colors
+// from the space each one is in. iTerm2 has no slots past the 16 ANSI colors.
+func readIterm(file []byte) (Theme, error) {
+	var plist plistNode
+	if err := xml.Unmarshal(file, &plist); err != nil {
+		return nil, err
+	}
+	if len(plist.Nodes) == 0 || plist.Nodes[0].XMLName.Local != "dict" {
+		return nil, fmt.Errorf("not a property list dict")
+	}
+	d := plist.Nodes[0].dict()
+
+	get := func(key string) (color.RGBA, error) {
+		n, found := d[key]
+		if !found {
+			return color.RGBA{}, fmt.Errorf("missing %s", key)
+		}
+		c, err := itermColor(n)
+		if err != nil {
+			return c, fmt.Errorf("%s: %w", key, err)
+		}
+		return c, nil
+	}
+
+	var t []color.Color
+	for i := 0; i < 16; i++ {
+		c, err := get(fmt.Sprintf("Ansi %d Color", i))
+		if err != nil {
+			return nil, err
+		}
+		t = append(t, c)
+	}
+	fg, err := get("Foreground Color")
+	if err != nil {
+		return nil, err
+	}
+	bg, err := get("Background Color")
+	if err != nil {
+		return nil, err
+	}
+	return make(Theme).Init(t, fg, bg), nil
+}
+
+func writeIterm(w io.Writer, m Meta, t Theme) error {
+	var b strings.Builder
+	slots := t.Slots()
+	if len(slots) < 16 {
+		return fmt.Errorf("theme has only %d colors", len(slots))
+	}
+
+	entry := func(key string, c color.RGBA) {
+		fmt.Fprintf(&b, "\t<key>%s</key>\n\t<dict>\n", key)
+		fmt.Fprintf(&b, "\t\t<key>Alpha Component</key>\n\t\t<real>1</real>\n")
+		fmt.Fprintf(&b, "\t\t<key>Blue Component</key>\n\t\t<real>%.8f</real>\n", float64(c.B)/0xff)
+		fmt.Fprintf(&b, "\t\t<key>Color Space</key>\n\t\t<string>sRGB</string>\n")
+		fmt.Fprintf(&b, "\t\t<key>Green Component</key>\n\t\t<real>%.8f</real>\n", float64(c.G)/0xff)
+		fmt.Fprintf(&b, "\t\t<key>Red Component</key>\n\t\t<real>%.8f</real>\n", float64(c.R)/0xff)
+		fmt.Fprintf(&b, "\t</dict>\n")
+	}
+
+	b.WriteString(xml.Header)
+	b.WriteString(`<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">` + "\n")
+	fmt.Fprintf(&b, "<!-- %s -->\n", m.header())
+	b.WriteString("<plist version=\"1.0\">\n<dict>\n")
+
+	for i, c := range slots[:16] {
+		entry(fmt.Sprintf("Ansi %d Color", i), t[c].RGBA)
+	}
+	fg, bg := t[Foreground].RGBA, t[Background].RGBA
+	entry("Background Color", bg)
+	entry("Bold Color", fg)
+	entry("Cursor Color", fg)
+	entry("Cursor Text Color", bg)
+	entry("Foreground Color", fg)
+	entry("Selected Text Color", bg)
+	entry("Selection Color", fg)
+
+	b.WriteString("</dict>\n</plist>\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// wtScheme is an entry of the "schemes" list in Windows Terminal's
+// settings.json.
+type wtScheme struct {
+	Name                string `json:"name"`
+	Foreground          string `json:"foreground"`
+	Background          string `json:"background"`
+	CursorColor         string `json:"cursorColor"`
+	SelectionBackground string `json:"selectionBackground"`
+
+	Black  string `json:"black"`
+	Red    string `json:"red"`
+	Green  string `json:"green"`
+	Yellow string `json:"yellow"`
+	Blue   string `json:"blue"`
+	Purple string `json:"purple"`
+	Cyan   string `json:"cyan"`
+	White  string `json:"white"`
+
+	BrightBlack  string `json:"brightBlack"`
+	BrightRed    string `json:"brightRed"`
+	BrightGreen  string `json:"brightGreen"`
+	BrightYellow string `json:"brightYellow"`
+	BrightBlue   string `json:"brightBlue"`
+	BrightPurple string `json:"brightPurple"`
+	BrightCyan   string `json:"brightCyan"`
+	BrightWhite  string `json:"brightWhite"`
+}
+
+func wtMeta(file []byte) Meta {
+	var settings struct {
+		Schemes []wtScheme `json:"schemes"`
+		wtScheme
+	}
+	json.Unmarshal(file, &settings)
+	if len(settings.Schemes) > 0 {
+		return Meta{Name: settings.Schemes[0].Name}
+	}
+	return Meta{Name: settings.Name}
+}
+
+// ansi returns the 16 ANSI fields in palette order.
+func (s *wtScheme) ansi() []*string {
+	return []*string{
+		&s.Black, &s.Red, &s.Green, &s.Yellow,
+		&s.Blue, &s.Purple, &s.Cyan, &s.White,
+		&s.BrightBlack, &s.BrightRed, &s.BrightGreen, &s.BrightYellow,
+		&s.BrightBlue, &s.BrightPurple, &s.BrightCyan, &s.BrightWhite,
+	}
+}
+
+// readWT reads a single scheme, or the first of the "schemes" in a whole
+// settings.json.
+func readWT(file []byte) (Theme, error) {
+	var settings struct {
+		Schemes []wtScheme `json:"schemes"`
+		wtScheme
+	}
+	if err := json.Unmarshal(file, &settings); err != nil {
+		return nil, err
+	}
+	s := &settings.wtScheme
+	if len(settings.Schemes) > 0 {
+		s = &settings.Schemes[0]
+	}
+
+	palette := make(map[int]string)
+	for i, spec := range s.ansi() {
+		if *spec != "" {
+			palette[i] = *spec
+		}
+	}
+	return paletteColors(palette, s.Foreground, s.Background)
+}
+
+// Windows Terminal schemes are plain JSON with no room for comments, so
+// only the name is kept.
+func writeWT(w io.Writer, m Meta, t Theme) error {
+	slots := t.Slots()
+	if len(slots) < 16 {
+		return fmt.Errorf("theme has only %d colors", len(slots))
+	}
+
+	s := &wtScheme{Name: m.Name}
+	hex := func(c tcell.Color) string {
+		return strings.ToUpper(hexColor(t[c].RGBA))
+	}
+	for i, spec := range s.ansi() {
+		*spec = hex(slots[i])
+	}
+	s.Foreground = hex(Foreground)
+	s.Background = hex(Background)
+	s.CursorColor = hex(Foreground)
+	s.SelectionBackground = hex(Foreground)
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "    ")
+	return enc.Encode(s)
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+// ansiTheme is sampleTheme cut down to the 16 colors iTerm2 and Windows
+// Terminal have.
+func ansiTheme() Theme {
+	theme := sampleTheme()
+	delete(theme, Black+16)
+	delete(theme, Black+17)
+	return theme
+}
+
+func TestItermRoundTrip(t *testing.T) {
+	roundTrip(t, "iterm", ansiTheme())
+}
+
+func TestWTRoundTrip(t *testing.T) {
+	roundTrip(t, "windows-terminal", ansiTheme())
+}
+
+// Slots past the 16 ANSI colors are left out.
+func TestAnsiOnly(t *testing.T) {
+	for _, fname := range []string{"iterm", "windows-terminal"} {
+		f, _ := formatByName(fname)
+		var b bytes.Buffer
+		if err := f.write(&b, Meta{Name: "Sample"}, sampleTheme()); err != nil {
+			t.Fatal(err)
+		}
+		theme, err := f.read(b.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !sameColors(theme, ansiTheme()) {
+			t.Errorf("%s: colors changed\n%s", fname, b.Bytes())
+		}
+	}
+}
+
+// A whole settings.json gives its first scheme.
+func TestWTSettings(t *testing.T) {
+	scheme := string(roundTrip(t, "windows-terminal", ansiTheme()))
+	other := strings.Replace(scheme, `"Sample"`, `"Other"`, 1)
+	other = strings.Replace(other, `"#181410"`, `"#000000"`, 1)
+	file := `{"profiles": {}, "schemes": [` + scheme + `,` + other + `]}`
+
+	theme, err := readWT([]byte(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sameColors(theme, ansiTheme()) {
+		t.Error("didn't read the first scheme")
+	}
+}
+
+// Components outside 0 to 1 are clamped.
+func TestItermClamp(t *testing.T) {
+	file := string(roundTrip(t, "iterm", ansiTheme()))
+	file = strings.Replace(file, "<real>0.05490196</real>", "<real>1.5</real>", 1)
+	file = strings.Replace(file, "<real>0.21960784</real>", "<real>-0.2</real>", 1)
+	theme, err := readIterm([]byte(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := theme[Red].RGBA, (color.RGBA{0xff, 0xf7, 0x28, 0xff}); got != want {
+		t.Errorf("red: got %v, want %v", got, want)
+	}
+	if got, want := theme[Blue].RGBA, (color.RGBA{0, 0xdf, 0x97, 0xff}); got != want {
+		t.Errorf("blue: got %v, want %v", got, want)
+	}
+}
+
+func TestItermColorSpace(t *testing.T) {
+	for _, test := range []struct {
+		space   string
+		r, g, b float64
+		want    color.RGBA
+	}{
+		{"<string>sRGB</string>", 1, 0.5, 0, color.RGBA{0xff, 0x80, 0, 0xff}},
+		// sRGB red, as Display P3 has it
+		{"<string>P3</string>", 0.9175, 0.2003, 0.1386, color.RGBA{0xff, 0, 0, 0xff}},
+		// P3 green is out of sRGB's gamut
+		{"<string>P3</string>", 0, 1, 0, color.RGBA{0, 0xff, 0, 0xff}},
+		// Generic RGB's gamma is 1.8
+		{"<string>Calibrated</string>", 0.5, 0.5, 0.5, color.RGBA{0x92, 0x92, 0x92, 0xff}},
+		{"", 0.5, 0.5, 0.5, color.RGBA{0x92, 0x92, 0x92, 0xff}},
+	} {
+		file := "<dict>"
+		if test.space != "" {
+			file += "<key>Color Space</key>" + test.space
+		}
+		file += fmt.Sprintf("<key>Red Component</key><real>%g</real>"+
+			"<key>Green Component</key><real>%g</real>"+
+			"<key>Blue Component</key><real>%g</real></dict>", test.r, test.g, test.b)
+		var n plistNode
+		if err := xml.Unmarshal([]byte(file), &n); err != nil {
+			t.Fatal(err)
+		}
+		got, err := itermColor(&n)
+		if err != nil {
+			t.Errorf("%s: %v", test.space, err)
+		} else if got != test.want {
+			t.Errorf("%s %g %g %g: got %v, want %v", test.space, test.r, test.g, test.b, got, test.want)
+		}
+	}
+}
+
+func TestItermMalformed(t *testing.T) {
+	file := string(roundTrip(t, "iterm", ansiTheme()))
+	readError(t, "iterm", "")
+	readError(t, "iterm", "<plist><array></array></plist>")
+	readError(t, "iterm", strings.Replace(file, "Ansi 5 Color", "Ansi 5 Colour", 1))
+	readError(t, "iterm", strings.Replace(file, "Red Component", "Rouge Component", 1))
+	readError(t, "iterm", strings.Replace(file, "<real>0.05490196</real>", "<real>x</real>", 1))
+	readError(t, "iterm", strings.TrimSuffix(file, "</plist>\n"))
+
+	_, err := readIterm([]byte(strings.Replace(file, "<string>sRGB</string>", "<string>Device</string>", 1)))
+	var perr *ParseError
+	if !errors.As(err, &perr) {
+		t.Errorf("unknown color space: got %v, want a ParseError", err)
+	}
+}
+
+func TestWTMalformed(t *testing.T) {
+	file := string(roundTrip(t, "windows-terminal", ansiTheme()))
+	readError(t, "windows-terminal", "")
+	readError(t, "windows-terminal", "{}")
+	readError(t, "windows-terminal", strings.Replace(file, `"brightBlue"`, `"brightBlu"`, 1))
+	readError(t, "windows-terminal", strings.Replace(file, `"purple": "#`, `"purple": "`, 1))
+	readError(t, "windows-terminal", strings.TrimSuffix(file, "}\n"))
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/gdamore/tcell/v2"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strconv"
+)
+
+var RE_slotName = regexp.MustCompile(`^color(\d+)$`)
+
+// ThemeFile is the native theme format, and how edited themes are stored.
+//
+//	{
+//	  "name": "ocean-cx",
+//	  "author": "…",
+//	  "parent": "ocean",
+//	  "variant": "dark",
+//	  "colors": {
+//	    "black": "#2b303b",
+//	    …
+//	    "bright-white": "#eff1f5",
+//	    "color16": "#d08770",
+//	    …
+//	    "foreground": "#c0c5ce",
+//	    "background": "#2b303b"
+//	  }
+//	}
+//
+// Slots are keyed by their long name in cNames, and palette slots past the
+// 16 ANSI colors as "color<N>". Everything but "colors" is optional.
+// "system" is set on themes saved from a base24 scheme, whose slots are
+// assigned differently.
+type ThemeFile struct {
+	Name    string  `json:"name,omitempty"`
+	Author  string  `json:"author,omitempty"`
+	Parent  string  `json:"parent,omitempty"`
+	Variant string  `json:"variant,omitempty"`
+	System  string  `json:"system,omitempty"`
+	Cursor  *Cursor `json:"cursor,omitempty"`
+	Colors  Theme   `json:"colors"`
+}
+
+func slotName(c tcell.Color) string {
+	if name, found := cNames[c]; found {
+		return name.string
+	}
+	return fmt.Sprintf("color%d", CMask(0).Index(c))
+}
+
+func slotByName(name string) (tcell.Color, bool) {
+	for c, n := range cNames {
+		if n.string == name {
+			return c, true
+		}
+	}
+	if match := RE_slotName.FindStringSubmatch(name); len(match) > 1 {
+		if index, err := strconv.ParseUint(match[1], 10, 8); err == nil {
+			return tcell.ColorValid + tcell.Color(index), true
+		}
+	}
+	return 0, false
+}
+
+func (b bColor) MarshalText() ([]byte, error) {
+	return []byte(hexColor(b.RGBA)), nil
+}
+
+func (b *bColor) UnmarshalText(text []byte) error {
+	if len(text) != 7 || text[0] != '#' {
+		return fmt.Errorf("%w %q", ErrBadColor, text)
+	}
+	c, err := FromHex(string(text))
+	if err != nil {
+		return err
+	}
+	*b = Bcolor(c)
+	return nil
+}
+
+// Slots are written in palette order rather than json's sorted map order.
+func (t Theme) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, c := range append(t.Slots(), Foreground, Background) {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		key, err := json.Marshal(slotName(c))
+		if err != nil {
+			return nil, err
+		}
+		val, err := json.Marshal(t[c])
+		if err != nil {
+			return nil, err
+		}
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(val)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+func (t *Theme) UnmarshalJSON(data []byte) error {
+	var named map[string]bColor
+	if err := json.Unmarshal(data, &named); err != nil {
+		return err
+	}
+
+	*t = make(Theme)
+	for name, bc := range named {
+		c, found := slotByName(name)
+		if !found {
+			return fmt.Errorf("unknown color slot %q", name)
+		}
+		(*t)[c] = bc
+	}
+
+	// the palette has to be contiguous for Slots
+	if len(t.Slots())+2 != len(*t) {
+		return fmt.Errorf("palette slots missing or out of range")
+	}
+	for _, c := range []tcell.Color{Foreground, Background} {
+		if _, found := (*t)[c]; !found {
+			return fmt.Errorf("missing %s", slotName(c))
+		}
+	}
+	return nil
+}
+
+func (t Theme) Variant() string {
+	if t[Background].Y < 0x80 {
+		return "dark"
+	}
+	return "light"
+}
+
+func readJSON(file []byte) (*ThemeFile, error) {
+	tf := new(ThemeFile)
+	if err := json.Unmarshal(file, tf); err != nil {
+		return nil, err
+	}
+	return tf, nil
+}
+
+func (tf *ThemeFile) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(tf)
+}
+
+// SaveJSON writes tf to DataDir as name, where the library will pick it up.
+func SaveJSON(name string, tf *ThemeFile) (string, error) {
+	path := filepath.Join(DataDir(), name+".json")
+	return path, writeFile(path, tf.Write)
+}
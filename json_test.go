@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+// sampleTheme has a different color in every slot, and two past the ANSI
+// ones.
+func sampleTheme() Theme {
+	cs := make([]color.Color, 18)
+	for i := range cs {
+		cs[i] = color.RGBA{uint8(i * 14), uint8(0xff - i*8), uint8(i*37 + 3), 0xff}
+	}
+	return make(Theme).Init(cs, color.RGBA{0xd0, 0xd0, 0xc8, 0xff}, color.RGBA{0x18, 0x14, 0x10, 0xff})
+}
+
+// sameColors is whether both themes have the same slots and colors.
+func sameColors(a, b Theme) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for c, bc := range a {
+		if b[c].RGBA != bc.RGBA {
+			return false
+		}
+	}
+	return true
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	theme := sampleTheme()
+	var b bytes.Buffer
+	if err := (&ThemeFile{Name: "sample", Parent: "base", Colors: theme}).Write(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	tf := new(ThemeFile)
+	if err := json.Unmarshal(b.Bytes(), tf); err != nil {
+		t.Fatalf("%v\n%s", err, b.Bytes())
+	}
+	if !sameColors(tf.Colors, theme) || tf.Name != "sample" || tf.Parent != "base" {
+		t.Errorf("got %+v\n%s", tf, b.Bytes())
+	}
+
+	// palette order, not sorted
+	file := b.String()
+	order := []string{`"black"`, `"red"`, `"bright-white"`, `"color16"`, `"color17"`, `"foreground"`, `"background"`}
+	for i := 1; i < len(order); i++ {
+		if strings.Index(file, order[i-1]) > strings.Index(file, order[i]) {
+			t.Errorf("%s written after %s", order[i-1], order[i])
+		}
+	}
+}
+
+func TestJSONMalformed(t *testing.T) {
+	var b bytes.Buffer
+	if err := (&ThemeFile{Colors: sampleTheme()}).Write(&b); err != nil {
+		t.Fatal(err)
+	}
+	file := b.String()
+	for _, tc := range []struct {
+		file string
+		want error
+	}{
+		{`{"colors": {}}`, nil},
+		{`{"colors": []}`, nil},
+		{strings.Replace(file, `"#00ff03"`, `"#00ff0"`, 1), ErrBadColor},
+		{strings.Replace(file, `"#00ff03"`, `"00ff03"`, 1), ErrBadColor},
+		{strings.Replace(file, `"#00ff03"`, `"#zzff03"`, 1), ErrBadColor},
+		{strings.Replace(file, `"black"`, `"blak"`, 1), nil},
+		{strings.Replace(file, `"color16"`, `"color19"`, 1), nil},
+		{strings.Replace(file, `"background"`, `"color18"`, 1), nil},
+	} {
+		err := json.Unmarshal([]byte(tc.file), new(ThemeFile))
+		switch {
+		case err == nil:
+			t.Errorf("no error reading %s", tc.file)
+		case tc.want != nil && !errors.Is(err, tc.want):
+			t.Errorf("got %v, want %v", err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"image/color"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	RE_kittyAssn = regexp.MustCompile(`^(\w+)\s+(\S+)`)
+	RE_iniHeader = regexp.MustCompile(`^\[\s*([\w-]+)\s*\]`)
+	RE_iniAssn   = regexp.MustCompile(`^([\w-]+)\s*=\s*(\S+)`)
+	RE_footSlot  = regexp.MustCompile(`^(regular|bright)?(\d+)$`)
+	RE_kittyMeta = regexp.MustCompile(`(?m)^##\s*(name|author):\s*(.*\S)`)
+)
+
+// readKitty reads a kitty.conf or kitty theme. The cursor and selection
+// colors have no slot in a Theme; kittyMeta keeps them.
+func readKitty(file []byte) (Theme, error) {
+	palette := make(map[int]string)
+	var fg, bg string
+
+	scan := bufio.NewScanner(bytes.NewReader(file))
+	for scan.Scan() {
+		match := RE_kittyAssn.FindStringSubmatch(strings.TrimSpace(scan.Text()))
+		if match == nil {
+			continue
+		}
+		key, val := match[1], match[2]
+		switch key {
+		case "foreground":
+			fg = val
+		case "background":
+			bg = val
+		default:
+			if index, found := shellKeyIndex(key); found {
+				palette[index] = val
+			}
+		}
+	}
+	return paletteColors(palette, fg, bg)
+}
+
+// kittyMeta reads the "## name:" and "## author:" header of kitty-themes,
+// the scheme system of exported ones, and the cursor and selection colors. Settings like "none" that aren't
+// colors are left to kitty's defaults.
+func kittyMeta(file []byte) Meta {
+	var m Meta
+	for _, match := range RE_kittyMeta.FindAllSubmatch(file, -1) {
+		switch string(match[1]) {
+		case "name":
+			m.Name = string(match[2])
+		case "author":
+			m.Author = string(match[2])
+		}
+	}
+
+	m.System = headerMeta(file).System
+
+	var cur Cursor
+	keys := map[string]*string{
+		"cursor":               &cur.Color,
+		"cursor_text_color":    &cur.Text,
+		"selection_foreground": &cur.SelectionFg,
+		"selection_background": &cur.SelectionBg,
+	}
+	scan := bufio.NewScanner(bytes.NewReader(file))
+	for scan.Scan() {
+		match := RE_kittyAssn.FindStringSubmatch(strings.TrimSpace(scan.Text()))
+		if match == nil || keys[match[1]] == nil {
+			continue
+		}
+		if c, err := FromHex(match[2]); err == nil {
+			*keys[match[1]] = hexColor(c)
+		}
+	}
+	if cur != (Cursor{}) {
+		m.Cursor = &cur
+	}
+	return m
+}
+
+// Cursor and selection colors are only written when the theme has them.
+func writeKitty(w io.Writer, m Meta, t Theme) error {
+	var b strings.Builder
+	fg, bg := hexColor(t[Foreground].RGBA), hexColor(t[Background].RGBA)
+
+	fmt.Fprintf(&b, "# %s\n\n", m.header())
+	fmt.Fprintf(&b, "## name: %s\n", m.Name)
+	if m.Author != "" {
+		fmt.Fprintf(&b, "## author: %s\n", m.Author)
+	}
+	b.WriteString("\n")
+	fmt.Fprintf(&b, "foreground %s\nbackground %s\n", fg, bg)
+	if cur := m.Cursor; cur != nil {
+		for _, kv := range [][2]string{
+			{"cursor", cur.Color},
+			{"cursor_text_color", cur.Text},
+			{"selection_foreground", cur.SelectionFg},
+			{"selection_background", cur.SelectionBg},
+		} {
+			if kv[1] != "" {
+				fmt.Fprintf(&b, "%s %s\n", kv[0], kv[1])
+			}
+		}
+	}
+	b.WriteString("\n")
+
+	for i, c := range t.Slots() {
+		fmt.Fprintf(&b, "color%d %s\n", i, hexColor(t[c].RGBA))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// readFoot reads the [colors] section of a foot.ini. Besides regular0-7 and
+// bright0-7, foot takes palette indices 16-255 as plain numbers.
+func readFoot(file []byte) (Theme, error) {
+	palette := make(map[int]string)
+	var section, fg, bg string
+
+	scan := bufio.NewScanner(bytes.NewReader(file))
+	for scan.Scan() {
+		line := strings.TrimSpace(scan.Text())
+		if match := RE_iniHeader.FindStringSubmatch(line); len(match) > 1 {
+			section = match[1]
+			continue
+		}
+		if section != "colors" && section != "colors-dark" {
+			continue
+		}
+		match := RE_iniAssn.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		key, val := match[1], "#"+match[2]
+		switch key {
+		case "foreground":
+			fg = val
+		case "background":
+			bg = val
+		default:
+			if slot := RE_footSlot.FindStringSubmatch(key); slot != nil {
+				index, _ := strconv.Atoi(slot[2])
+				switch {
+				case slot[1] == "bright" && index < 8:
+					palette[8+index] = val
+				case slot[1] == "regular" && index < 8:
+					palette[index] = val
+				case slot[1] == "" && index >= 16:
+					palette[index] = val
+				}
+			}
+		}
+	}
+	return paletteColors(palette, fg, bg)
+}
+
+func writeFoot(w io.Writer, m Meta, t Theme) error {
+	var b strings.Builder
+	rrggbb := func(c color.RGBA) string {
+		return strings.TrimPrefix(hexColor(c), "#")
+	}
+
+	fmt.Fprintf(&b, "# %s\n\n[colors]\n", m.header())
+	fmt.Fprintf(&b, "foreground=%s\n", rrggbb(t[Foreground].RGBA))
+	fmt.Fprintf(&b, "background=%s\n", rrggbb(t[Background].RGBA))
+
+	for i, c := range t.Slots() {
+		switch {
+		case i < 8:
+			fmt.Fprintf(&b, "regular%d=%s\n", i, rrggbb(t[c].RGBA))
+		case i < 16:
+			fmt.Fprintf(&b, "bright%d=%s\n", i-8, rrggbb(t[c].RGBA))
+		default:
+			fmt.Fprintf(&b, "%d=%s\n", i, rrggbb(t[c].RGBA))
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestKittyRoundTrip(t *testing.T) {
+	file := string(roundTrip(t, "kitty", sampleTheme()))
+	// a theme without cursor colors leaves them to kitty
+	for _, key := range []string{"cursor", "cursor_text_color", "selection_foreground", "selection_background"} {
+		if strings.Contains(file, "\n"+key+" ") {
+			t.Errorf("%s written", key)
+		}
+	}
+}
+
+func TestKittyCursor(t *testing.T) {
+	file := "cursor #FF0000\ncursor_text_color background\nselection_background #00ff00\n" +
+		string(roundTrip(t, "kitty", sampleTheme()))
+	m := kittyMeta([]byte(file))
+	want := Cursor{Color: "#ff0000", SelectionBg: "#00ff00"}
+	if m.Cursor == nil || *m.Cursor != want {
+		t.Fatalf("read cursor %+v, want %+v", m.Cursor, want)
+	}
+
+	// through a saved theme and back out
+	kitty, _ := formatByName("kitty")
+	json, _ := formatByName("json")
+	var b bytes.Buffer
+	if err := json.write(&b, m, sampleTheme()); err != nil {
+		t.Fatal(err)
+	}
+	m = json.meta(b.Bytes())
+	b.Reset()
+	if err := kitty.write(&b, m, sampleTheme()); err != nil {
+		t.Fatal(err)
+	}
+	if got := kittyMeta(b.Bytes()).Cursor; got == nil || *got != want {
+		t.Errorf("wrote cursor %+v, want %+v\n%s", got, want, b.Bytes())
+	}
+	if strings.Contains(b.String(), "cursor_text_color") {
+		t.Errorf("cursor_text_color written\n%s", b.Bytes())
+	}
+}
+
+func TestKittyConf(t *testing.T) {
+	file := string(roundTrip(t, "kitty", sampleTheme()))
+	// settings that aren't palette colors are passed over
+	file = "include theme.conf\n  # color0 #ffffff\nfont_size 11.0\nurl_color #ff0000\nmark1_foreground #ff0000\n" +
+		strings.Replace(file, "color1 ", "color1\t  ", 1)
+	theme, err := readKitty([]byte(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sameColors(theme, sampleTheme()) {
+		t.Error("colors changed")
+	}
+}
+
+func TestFootRoundTrip(t *testing.T) {
+	file := string(roundTrip(t, "foot", sampleTheme()))
+	for _, key := range []string{"\nregular0=", "\nbright7=", "\n17="} {
+		if !strings.Contains(file, key) {
+			t.Errorf("no %s written", strings.TrimSpace(key))
+		}
+	}
+}
+
+func TestFootSections(t *testing.T) {
+	file := string(roundTrip(t, "foot", sampleTheme()))
+	// only [colors] counts, or [colors-dark] in newer configs
+	outside := "[cursor]\ncolor=ff0000 00ff00\n[main]\nregular0=ffffff\n" + file + "\n[colors2]\nregular0=ffffff\n"
+	for _, file := range []string{outside, strings.Replace(file, "[colors]", "[colors-dark]", 1)} {
+		theme, err := readFoot([]byte(file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !sameColors(theme, sampleTheme()) {
+			t.Errorf("colors changed reading\n%s", file)
+		}
+	}
+}
+
+func TestKittyMalformed(t *testing.T) {
+	file := string(roundTrip(t, "kitty", sampleTheme()))
+	readError(t, "kitty", "")
+	readError(t, "kitty", strings.Replace(file, "color9 ", "colour9 ", 1))
+	readError(t, "kitty", strings.Replace(file, "color3 #", "color3 ", 1))
+	readError(t, "kitty", strings.Replace(file, "foreground #", "foreground #x", 1))
+}
+
+func TestFootMalformed(t *testing.T) {
+	file := string(roundTrip(t, "foot", sampleTheme()))
+	readError(t, "foot", "")
+	readError(t, "foot", strings.Replace(file, "[colors]", "[colours]", 1))
+	readError(t, "foot", strings.Replace(file, "bright7=", "bright8=", 1))
+	readError(t, "foot", strings.Replace(file, "regular2=", "regular2=x", 1))
+}
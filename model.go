@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/gdamore/tcell/v2"
 	"image/color"
+	"math"
 	"os"
 	"strconv"
 )
@@ -17,6 +18,18 @@ const (
 	R
 	G
 	B
+	OkL
+	OkA
+	OkB
+	OkC
+	OkH
+	Hue
+	HslS
+	HslL
+	HsvS
+	HsvV
+	CbCrH
+	CbCrC
 )
 
 type DispName struct {
@@ -31,6 +44,21 @@ var propNames = map[cprop8]DispName{
 	R:  {"R", "red"},
 	G:  {"G", "green"},
 	B:  {"B", "blue"},
+
+	OkL: {"L", "ok-lightness"},
+	OkA: {"a", "green-red"},
+	OkB: {"b", "blue-yellow"},
+	OkC: {"C", "ok-chroma"},
+	OkH: {"h", "ok-hue"},
+
+	Hue:  {"H", "hue"},
+	HslS: {"S", "hsl-saturation"},
+	HslL: {"L", "hsl-lightness"},
+	HsvS: {"S", "hsv-saturation"},
+	HsvV: {"V", "value"},
+
+	CbCrH: {"h", "cbcr-hue"},
+	CbCrC: {"C", "cbcr-chroma"},
 }
 
 // classic nourishing ANSI names.
@@ -87,20 +115,131 @@ func (p cprop8) String() string {
 	return propNames[p].string
 }
 
+// Max is the top of the property's scale: 360° for hues, 255 otherwise.
+func (p cprop8) Max() int {
+	if p.Wraps() {
+		return 360
+	}
+	return 0xff
+}
+
+// Wraps is true for hues, which go round rather than stopping at the ends.
+func (p cprop8) Wraps() bool {
+	return p == OkH || p == Hue || p == CbCrH
+}
+
+// Scales is true for properties that are adjusted by a factor rather than
+// an offset, so that everything selected keeps its proportions.
+func (p cprop8) Scales() bool {
+	return p == CbCrC
+}
+
+// bColor keeps a color in every model the Console adjusts, in sync. rgb is
+// the color itself; the embedded 8-bit RGBA and YCbCr are only rounded from
+// it, for display and Apply. The polar models are kept besides the ones
+// they derive from so that hue survives a trip through gray.
 type bColor struct {
 	color.RGBA
 	color.YCbCr
+	rgb RGB
+	ycc YCC
+	ych YCH
+	lab OKLab
+	lch OKLCH
+	hsl HSL
+	hsv HSV
 }
 
 type Theme map[tcell.Color]bColor
 type CMask uint64
 
-func toYUV(c color.Color) color.YCbCr {
-	return color.YCbCrModel.Convert(c).(color.YCbCr)
+// model is a color model bColor keeps in float precision.
+type model interface {
+	color.Color
+	rgb() RGB
+}
+
+// RGB is sRGB with components from 0 to 1. They're left unclamped, so that
+// an edit that goes out of gamut and comes back is undone exactly.
+type RGB struct {
+	R, G, B float64
+}
+
+// YCC is JFIF Y′CbCr, as in color.YCbCr, scaled from 0 to 1.
+type YCC struct {
+	Y, Cb, Cr float64
+}
+
+func round8(v float64) uint8 {
+	return uint8(math.Round(clamp01(v) * 0xff))
+}
+
+func (c RGB) rgb() RGB {
+	return c
+}
+
+func (c RGB) RGBA() (uint32, uint32, uint32, uint32) {
+	conv := func(v float64) uint32 {
+		return uint32(math.Round(clamp01(v) * 0xffff))
+	}
+	return conv(c.R), conv(c.G), conv(c.B), 0xffff
+}
+
+// RGBA8 rounds to the nearest 8-bit color, clamping anything out of gamut.
+func (c RGB) RGBA8() color.RGBA {
+	return color.RGBA{round8(c.R), round8(c.G), round8(c.B), 0xff}
+}
+
+func toYCC(c RGB) YCC {
+	return YCC{
+		0.299*c.R + 0.587*c.G + 0.114*c.B,
+		-0.168736*c.R - 0.331264*c.G + 0.5*c.B + 0.5,
+		0.5*c.R - 0.418688*c.G - 0.081312*c.B + 0.5,
+	}
+}
+
+// rgb inverts toYCC exactly, rather than with the rounded coefficients
+// usually quoted.
+func (c YCC) rgb() RGB {
+	r := c.Y + 1.402*(c.Cr-0.5)
+	b := c.Y + 1.772*(c.Cb-0.5)
+	return RGB{r, (c.Y - 0.299*r - 0.114*b) / 0.587, b}
+}
+
+func (c YCC) RGBA() (uint32, uint32, uint32, uint32) {
+	return c.rgb().RGBA()
+}
+
+// YCH is Y′CbCr in polar form: the (Cb, Cr) vector from neutral as a length
+// C and an angle H in degrees.
+type YCH struct {
+	Y, C, H float64
+}
+
+// CBCR_C is the length of the (Cb, Cr) vector scaled to 255; sRGB's reds
+// and blues reach about 0.53.
+const CBCR_C = 0.6
+
+func (c YCC) polar() YCH {
+	cb, cr := c.Cb-0.5, c.Cr-0.5
+	h := math.Atan2(cr, cb) * 180 / math.Pi
+	if h < 0 {
+		h += 360
+	}
+	return YCH{c.Y, math.Hypot(cb, cr), h}
+}
+
+func (c YCH) ycc() YCC {
+	h := c.H * math.Pi / 180
+	return YCC{c.Y, 0.5 + c.C*math.Cos(h), 0.5 + c.C*math.Sin(h)}
+}
+
+func (c YCH) rgb() RGB {
+	return c.ycc().rgb()
 }
 
-func toRGB(c color.Color) color.RGBA {
-	return color.RGBAModel.Convert(c).(color.RGBA)
+func (c YCH) RGBA() (uint32, uint32, uint32, uint32) {
+	return c.rgb().RGBA()
 }
 
 func (p Theme) Init(t []color.Color, fg, bg color.Color) Theme {
@@ -121,19 +260,56 @@ func (t Theme) Copy() Theme {
 	return tc
 }
 
-func (p Theme) From(hex []string, fg, bg string) Theme {
+// Equal is whether both themes have the same colors, as displayed.
+func (t Theme) Equal(o Theme) bool {
+	if len(t) != len(o) {
+		return false
+	}
+	for c, b := range t {
+		if ob, found := o[c]; !found || ob.RGBA != b.RGBA {
+			return false
+		}
+	}
+	return true
+}
+
+// Slots returns the theme's palette indices in order, without Foreground and
+// Background.
+func (t Theme) Slots() []tcell.Color {
+	var slots []tcell.Color
+	for c := Black; ; c++ {
+		if _, found := t[c]; !found {
+			return slots
+		}
+		slots = append(slots, c)
+	}
+}
+
+func (p Theme) From(hex []string, fg, bg string) (Theme, error) {
 	var t []color.Color
-	for _, h := range hex {
-		t = append(t, FromHex(h))
+	for i, h := range hex {
+		c, err := FromHex(h)
+		if err != nil {
+			return nil, fmt.Errorf("color%02d: %w", i, err)
+		}
+		t = append(t, c)
 	}
 
-	fg_c := FromHex(fg)
-	bg_c := FromHex(bg)
+	fg_c, err := FromHex(fg)
+	if err != nil {
+		return nil, fmt.Errorf("foreground: %w", err)
+	}
+	bg_c, err := FromHex(bg)
+	if err != nil {
+		return nil, fmt.Errorf("background: %w", err)
+	}
 
-	return p.Init(t, fg_c, bg_c)
+	return p.Init(t, fg_c, bg_c), nil
 }
 
-func FromHex(hex string) color.Color {
+// FromHex takes the six hex digits out of hex, ignoring anything else, so
+// both #rrggbb and base16-shell's rr/gg/bb are understood.
+func FromHex(hex string) (color.RGBA, error) {
 	var runes []rune
 	for _, r := range hex {
 		if (r >= '0' && r <= '9') ||
@@ -143,13 +319,17 @@ func FromHex(hex string) color.Color {
 		}
 	}
 	if len(runes) != 6 {
-		panic(fmt.Errorf("bad hex color %s (%v)", hex, runes))
+		return color.RGBA{}, fmt.Errorf("%w %q", ErrBadColor, hex)
 	}
 	val, err := strconv.ParseUint(string(runes), 16, 32)
 	if err != nil {
-		panic("bad hex color")
+		return color.RGBA{}, fmt.Errorf("%w %q", ErrBadColor, hex)
 	}
-	return color.RGBA{uint8(val >> 16), uint8(val >> 8), uint8(val), 0xff}
+	return color.RGBA{uint8(val >> 16), uint8(val >> 8), uint8(val), 0xff}, nil
+}
+
+func hexColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
 }
 
 func initc(code string, color color.RGBA) {
@@ -161,8 +341,9 @@ func initc(code string, color color.RGBA) {
 	)
 }
 
+// Apply sends the theme to the terminal, as seen with SIMULATE.
 func (t Theme) Apply() Theme {
-	for c, bc := range t {
+	for c, bc := range SIMULATE.Simulate(t) {
 		switch c {
 		case Foreground:
 			initc(fmt.Sprintf("%d", 10), bc.RGBA)
@@ -176,71 +357,182 @@ func (t Theme) Apply() Theme {
 	return t
 }
 
-func (b bColor) Access(prop cprop8) uint8 {
+// Value is the property on its own scale, 0 to prop.Max(), unrounded.
+func (b bColor) Value(prop cprop8) float64 {
 	switch prop {
 	case Y:
-		return b.Y
+		return b.ycc.Y * 0xff
 	case Cb:
-		return b.Cb
+		return b.ycc.Cb * 0xff
 	case Cr:
-		return b.Cr
+		return b.ycc.Cr * 0xff
 	case R:
-		return b.R
+		return b.rgb.R * 0xff
 	case G:
-		return b.G
+		return b.rgb.G * 0xff
 	case B:
-		return b.B
+		return b.rgb.B * 0xff
+	case OkL:
+		return scale(b.lab.L, 0, 1)
+	case OkA:
+		return scale(b.lab.A, -OKLAB_AB, OKLAB_AB)
+	case OkB:
+		return scale(b.lab.B, -OKLAB_AB, OKLAB_AB)
+	case OkC:
+		return scale(b.lch.C, 0, OKLCH_C)
+	case OkH:
+		return b.lch.H
+	case Hue:
+		return b.hsl.H
+	case HslS:
+		return scale(b.hsl.S, 0, 1)
+	case HslL:
+		return scale(b.hsl.L, 0, 1)
+	case HsvS:
+		return scale(b.hsv.S, 0, 1)
+	case HsvV:
+		return scale(b.hsv.V, 0, 1)
+	case CbCrH:
+		return b.ych.H
+	case CbCrC:
+		return scale(b.ych.C, 0, CBCR_C)
 	}
 	panic("bad access")
 }
 
+// Access is the property rounded for display.
+func (b bColor) Access(prop cprop8) int {
+	val := int(math.Round(b.Value(prop)))
+	if prop.Wraps() {
+		val %= prop.Max()
+	}
+	return val
+}
+
 func (b bColor) Set(c color.Color) bColor {
 	return Bcolor(c)
 }
 
-func (b bColor) SetProp(prop cprop8, val uint8) bColor {
+// SetValue takes val on the property's own scale, 0 to prop.Max(). Only the
+// model the property belongs to is set directly; the rest follow from it.
+func (b bColor) SetValue(prop cprop8, val float64) bColor {
 	switch prop {
 	case Y:
-		b.Y = val
+		b.ycc.Y = val / 0xff
 	case Cb:
-		b.Cb = val
+		b.ycc.Cb = val / 0xff
 	case Cr:
-		b.Cr = val
+		b.ycc.Cr = val / 0xff
 	case R:
-		b.R = val
+		b.rgb.R = val / 0xff
 	case G:
-		b.G = val
+		b.rgb.G = val / 0xff
 	case B:
-		b.B = val
+		b.rgb.B = val / 0xff
+	case OkL:
+		b.lab.L = unscale(val, 0, 1)
+		b.lch.L = b.lab.L
+	case OkA:
+		b.lab.A = unscale(val, -OKLAB_AB, OKLAB_AB)
+	case OkB:
+		b.lab.B = unscale(val, -OKLAB_AB, OKLAB_AB)
+	case OkC:
+		b.lch.C = unscale(val, 0, OKLCH_C)
+	case OkH:
+		b.lch.H = val
+	case Hue:
+		b.hsl.H = val
+		b.hsv.H = b.hsl.H
+	case HslS:
+		b.hsl.S = unscale(val, 0, 1)
+	case HslL:
+		b.hsl.L = unscale(val, 0, 1)
+	case HsvS:
+		b.hsv.S = unscale(val, 0, 1)
+	case HsvV:
+		b.hsv.V = unscale(val, 0, 1)
+	case CbCrH:
+		b.ych.H = val
+	case CbCrC:
+		b.ych.C = unscale(val, 0, CBCR_C)
 	}
 	switch prop {
 	case Y, Cb, Cr:
-		return Bcolor(b.YCbCr)
+		return Bcolor(b.ycc)
 	case R, G, B:
-		return Bcolor(b.RGBA)
+		return Bcolor(b.rgb)
+	case OkL, OkA, OkB:
+		return Bcolor(b.lab)
+	case OkC, OkH:
+		return Bcolor(b.lch)
+	case Hue, HslS, HslL:
+		return Bcolor(b.hsl)
+	case HsvS, HsvV:
+		return Bcolor(b.hsv)
+	case CbCrH, CbCrC:
+		return Bcolor(b.ych)
 	}
 	panic("invalid property")
 }
 
+func (b bColor) SetProp(prop cprop8, val int) bColor {
+	return b.SetValue(prop, float64(val))
+}
+
+// Adjust clamps the property to its scale, except for hues, which wrap
+// around. Nothing is rounded, so an adjustment undone is undone exactly.
+// Properties that scale are multiplied by 2^(adj/32) instead.
 func (b bColor) Adjust(prop cprop8, adj int) bColor {
-	val := int(b.Access(prop)) + adj
-	if val < 0 {
+	val, max := b.Value(prop), float64(prop.Max())
+	if prop.Scales() {
+		val *= math.Pow(2, float64(adj)/32)
+	} else {
+		val += float64(adj)
+	}
+	switch {
+	case prop.Wraps():
+		val = math.Mod(math.Mod(val, max)+max, max)
+	case val < 0:
 		val = 0
-	} else if val > 0xff {
-		val = 0xff
+	case val > max:
+		val = max
 	}
-	return b.SetProp(prop, uint8(val))
+	return b.SetValue(prop, val)
 }
 
+// Bcolor fills in every model from c. The model c is in is kept as given,
+// rather than worked out again, and so are hues that the conversion would
+// lose. The embedded 8-bit colors are rounded from the rest.
 func Bcolor(c color.Color) bColor {
+	var rgb RGB
+	if m, ok := c.(model); ok {
+		rgb = m.rgb()
+	} else {
+		r, g, b, _ := c.RGBA()
+		rgb = RGB{float64(r) / 0xffff, float64(g) / 0xffff, float64(b) / 0xffff}
+	}
+	lab := toOKLab(rgb)
+	ycc := toYCC(rgb)
+	b := bColor{rgb: rgb, ycc: ycc, ych: ycc.polar(), lab: lab, lch: lab.LCH(), hsl: toHSL(rgb), hsv: toHSV(rgb)}
+
 	switch c0 := c.(type) {
-	case color.YCbCr:
-		return bColor{toRGB(c0), c0}
-	case color.RGBA:
-		return bColor{c0, toYUV(c0)}
-	default:
-		return bColor{toRGB(c0), toYUV(c0)}
+	case YCC:
+		b.ycc, b.ych = c0, c0.polar()
+	case YCH:
+		b.ycc, b.ych = c0.ycc(), c0
+	case OKLab:
+		b.lab, b.lch = c0, c0.LCH()
+	case OKLCH:
+		b.lab, b.lch = c0.Lab(), c0
+	case HSL:
+		b.hsl, b.hsv.H = c0, c0.H
+	case HSV:
+		b.hsv, b.hsl.H = c0, c0.H
 	}
+
+	b.RGBA = rgb.RGBA8()
+	b.YCbCr = color.YCbCr{round8(b.ycc.Y), round8(b.ycc.Cb), round8(b.ycc.Cr)}
+	return b
 }
 
 func first[T any](first T, _ ...any) T {
@@ -306,6 +598,12 @@ func (cm CMask) Colors() CMask {
 	return cm._Colors() | cm.BrightColors()
 }
 
+// Extras are the slots past the ANSI colors that base16-shell and base24
+// themes fill: 16 to 23.
+func (cm CMask) Extras() CMask {
+	return cm.Interval(Black+16, Black+23)
+}
+
 func (cm CMask) _Grays() CMask {
 	return cm.Mask(Black) | cm.Mask(White)
 }
@@ -316,6 +614,30 @@ func (cm CMask) Grays() CMask {
 	return cm._Grays() | cm.BrightGrays() | cm.Mask(Foreground) | cm.Mask(Background)
 }
 
+// Rebase carries the edits that turned from into t over to onto, as an RGB
+// offset per color. Both bases must have the same colors.
+func (t Theme) Rebase(from, onto Theme) Theme {
+	clamp := func(v int) uint8 {
+		if v < 0 {
+			return 0
+		} else if v > 0xff {
+			return 0xff
+		}
+		return uint8(v)
+	}
+	rebased := make(Theme)
+	for c, base := range onto {
+		edit, orig := t[c].RGBA, from[c].RGBA
+		rebased[c] = Bcolor(color.RGBA{
+			clamp(int(base.R) + int(edit.R) - int(orig.R)),
+			clamp(int(base.G) + int(edit.G) - int(orig.G)),
+			clamp(int(base.B) + int(edit.B) - int(orig.B)),
+			0xff,
+		})
+	}
+	return rebased
+}
+
 func (t Theme) Adjust(cm CMask, prop cprop8, adj int) Theme {
 	if adj == 0 || cm == 0 {
 		return t
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"github.com/gdamore/tcell/v2"
+	"image/color"
+	"testing"
+)
+
+var testColors = []color.RGBA{
+	{0, 0, 0xff, 0xff},
+	{0xff, 0, 0, 0xff},
+	{0x12, 0x34, 0x56, 0xff},
+	{0xcc, 0x99, 0x66, 0xff},
+	{0x80, 0x80, 0x80, 0xff},
+}
+
+// step applies adjustments one at a time, the way holding a key would.
+func step(b bColor, prop cprop8, adj int) bColor {
+	dir := 1
+	if adj < 0 {
+		dir, adj = -1, -adj
+	}
+	for ; adj > 0; adj-- {
+		b = b.Adjust(prop, dir)
+	}
+	return b
+}
+
+func TestAdjustUndo(t *testing.T) {
+	for _, c := range testColors {
+		for _, adj := range []int{1, 7, 40} {
+			b := step(step(Bcolor(c), Y, adj), Y, -adj)
+			if b.RGBA != c {
+				t.Errorf("%v: Y′ +%d -%d gave %v", c, adj, adj, b.RGBA)
+			}
+		}
+	}
+}
+
+func TestAdjustInterleaved(t *testing.T) {
+	for _, c := range testColors {
+		// R stops at the ends of its scale, and then can't be undone
+		if c.R < 10 || c.R > 0xff-10 {
+			continue
+		}
+		b := Bcolor(c)
+		b = step(b, R, 10)
+		b = step(b, Y, 20)
+		b = step(b, R, -10)
+		b = step(b, Y, -20)
+		if b.RGBA != c {
+			t.Errorf("%v: R and Y′ interleaved gave %v", c, b.RGBA)
+		}
+	}
+}
+
+func TestAdjustOutOfGamut(t *testing.T) {
+	blue := color.RGBA{0, 0, 0xff, 0xff}
+	b := Bcolor(blue).Adjust(Y, 100)
+	if v := b.Value(B); v <= 0xff {
+		t.Errorf("B is %f, want past 255", v)
+	}
+	if b.RGBA.B != 0xff || b.RGBA.R != 100 || b.RGBA.G != 100 {
+		t.Errorf("displayed as %v", b.RGBA)
+	}
+	if b = b.Adjust(Y, -100); b.RGBA != blue {
+		t.Errorf("Y′ back down gave %v", b.RGBA)
+	}
+
+	red := color.RGBA{0xff, 0, 0, 0xff}
+	b = Bcolor(red).Adjust(Y, -50)
+	if v := b.Value(G); v >= 0 {
+		t.Errorf("G is %f, want below 0", v)
+	}
+	if b = b.Adjust(Y, 50); b.RGBA != red {
+		t.Errorf("Y′ back up gave %v", b.RGBA)
+	}
+}
+
+func TestExtrasMask(t *testing.T) {
+	got := CMask(0).Extras().Iter()
+	if len(got) != 8 {
+		t.Fatalf("got %d slots, want 8", len(got))
+	}
+	for i, c := range got {
+		if c != Black+tcell.Color(16+i) {
+			t.Errorf("slot %d: got %v", 16+i, c)
+		}
+	}
+	if extras := CMask(0).Extras(); extras&(CMask(0).Colors()|CMask(0).Grays()) != 0 {
+		t.Error("extras overlap the ANSI colors")
+	}
+}
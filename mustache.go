@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"image/color"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	TEMPLATE_DIRS []string
+	RE_mustache   = regexp.MustCompile(`{{(?s:(!.*?)|([{&#^/]?)\s*([\w.-]+)\s*}?)}}`)
+	RE_tmplConfig = regexp.MustCompile(`^(\s*)([\w-]+):\s*(.*)`)
+)
+
+// Scheme slot for each base16 role that isn't found in the slot assignment
+// because the theme stops at 16 colors.
+var b16Fallback = map[int]int{
+	0x01: 0x00, 0x02: 0x08, 0x04: 0x08, 0x06: 0x07, 0x09: 0x01, 0x0F: 0x01,
+}
+
+// Base16 returns the theme's color for each base16 role, undoing the slot
+// assignment of its scheme system. base00 and base05 come from Background
+// and Foreground. base24 leaves base03 out of the palette, so it's taken
+// halfway between base02 and base04.
+func (t Theme) Base16(system string) [16]color.RGBA {
+	var base [16]color.RGBA
+	slots := t.Slots()
+
+	for role := range base {
+		index := -1
+		for i, slot := range systemSlots(system) {
+			if slot == role && i < len(slots) {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			index = b16Fallback[role]
+		}
+		base[role] = t[slots[index]].RGBA
+	}
+	base[0x00] = t[Background].RGBA
+	base[0x05] = t[Foreground].RGBA
+	if system == "base24" {
+		b2, b4 := base[0x02], base[0x04]
+		base[0x03] = color.RGBA{
+			uint8((int(b2.R) + int(b4.R)) / 2),
+			uint8((int(b2.G) + int(b4.G)) / 2),
+			uint8((int(b2.B) + int(b4.B)) / 2),
+			0xff,
+		}
+	}
+	return base
+}
+
+// TemplateVars returns the variables of the base16 builder spec. The slug
+// comes from name, the theme's name in the library.
+func (t Theme) TemplateVars(name string, m Meta) map[string]any {
+	system := m.System
+	if system == "" {
+		system = "base16"
+	}
+	slug := strings.ToLower(strings.Join(strings.Fields(name), "-"))
+	variant := m.Variant
+	if variant == "" {
+		variant = t.Variant()
+	}
+	vars := map[string]any{
+		"scheme-name":             m.Name,
+		"scheme-author":           m.Author,
+		"scheme-slug":             slug,
+		"scheme-slug-underscored": strings.ReplaceAll(slug, "-", "_"),
+		"scheme-system":           system,
+		"scheme-variant":          variant,
+		"scheme-is-dark-variant":  variant == "dark",
+		"scheme-is-light-variant": variant == "light",
+	}
+
+	for role, c := range t.Base16(m.System) {
+		base := fmt.Sprintf("base%02X", role)
+		hex := strings.TrimPrefix(hexColor(c), "#")
+		vars[base+"-hex"] = hex
+		vars[base+"-hex-bgr"] = hex[4:6] + hex[2:4] + hex[0:2]
+		for i, comp := range []struct {
+			name string
+			val  uint8
+		}{{"r", c.R}, {"g", c.G}, {"b", c.B}} {
+			vars[base+"-hex-"+comp.name] = hex[2*i : 2*i+2]
+			vars[base+"-rgb-"+comp.name] = fmt.Sprintf("%d", comp.val)
+			vars[base+"-dec-"+comp.name] = fmt.Sprintf("%.8f", float64(comp.val)/0xff)
+		}
+	}
+	return vars
+}
+
+func truthy(val any) bool {
+	switch v := val.(type) {
+	case bool:
+		return v
+	case string:
+		return v != ""
+	}
+	return false
+}
+
+// RenderMustache renders the subset of mustache that base16 templates use:
+// variables, comments, and sections over booleans. Values aren't HTML
+// escaped, since the output is config files. Section tags and comments
+// alone on their lines take the lines with them, as the spec has it.
+func RenderMustache(tmpl string, vars map[string]any) (string, error) {
+	var b strings.Builder
+	// open sections, and whether their content is being written
+	var open []string
+	var show []bool
+	visible := func() bool {
+		return len(show) == 0 || show[len(show)-1]
+	}
+
+	pos := 0
+	for _, m := range RE_mustache.FindAllStringSubmatchIndex(tmpl, -1) {
+		start, end := m[0], m[1]
+		var sigil, key string
+		if m[2] < 0 {
+			sigil, key = tmpl[m[4]:m[5]], tmpl[m[6]:m[7]]
+		}
+		if m[2] >= 0 || sigil == "#" || sigil == "^" || sigil == "/" {
+			start, end = standalone(tmpl, pos, start, end)
+		}
+		if visible() {
+			b.WriteString(tmpl[pos:start])
+		}
+		pos = end
+
+		if m[2] >= 0 {
+			// comment
+			continue
+		}
+		switch sigil {
+		case "#", "^":
+			on := truthy(vars[key]) == (sigil == "#")
+			open = append(open, key)
+			show = append(show, visible() && on)
+		case "/":
+			if len(open) == 0 || open[len(open)-1] != key {
+				return "", fmt.Errorf("unexpected {{/%s}}", key)
+			}
+			open, show = open[:len(open)-1], show[:len(show)-1]
+		default:
+			if visible() {
+				if val, found := vars[key]; found {
+					fmt.Fprint(&b, val)
+				}
+			}
+		}
+	}
+	if len(open) > 0 {
+		return "", fmt.Errorf("unclosed {{#%s}}", open[len(open)-1])
+	}
+	b.WriteString(tmpl[pos:])
+	return b.String(), nil
+}
+
+// standalone widens the tag at tmpl[start:end] to its whole line, newline
+// included, when nothing else is on the line but whitespace. Section tags
+// and comments on lines of their own leave no blank line behind. Text
+// before pos has already been consumed.
+func standalone(tmpl string, pos, start, end int) (int, int) {
+	from := strings.LastIndexByte(tmpl[:start], '\n') + 1
+	to := strings.IndexByte(tmpl[end:], '\n')
+	if to < 0 {
+		to = len(tmpl)
+	} else {
+		to += end + 1
+	}
+	if from < pos || strings.TrimSpace(tmpl[from:start]) != "" || strings.TrimSpace(tmpl[end:to]) != "" {
+		return start, end
+	}
+	return from, to
+}
+
+// templateConfig reads the templates/config.yaml of a base16 template
+// repository: the output directory and file extension for each template.
+func templateConfig(file []byte) map[string]map[string]string {
+	config := make(map[string]map[string]string)
+	var tmpl string
+
+	scan := bufio.NewScanner(bytes.NewReader(file))
+	for scan.Scan() {
+		match := RE_tmplConfig.FindStringSubmatch(scan.Text())
+		if match == nil {
+			continue
+		}
+		if match[1] == "" {
+			tmpl = match[2]
+			config[tmpl] = make(map[string]string)
+		} else if tmpl != "" {
+			config[tmpl][match[2]] = strings.Trim(strings.TrimSpace(match[3]), `"'`)
+		}
+	}
+	return config
+}
+
+// RenderTemplates renders every template of a base16 template repository
+// against t, writing the results where a base16 builder would.
+func RenderTemplates(dir, name string, m Meta, t Theme) ([]string, error) {
+	file, err := os.ReadFile(filepath.Join(dir, "templates", "config.yaml"))
+	if err != nil {
+		return nil, err
+	}
+
+	vars := t.TemplateVars(name, m)
+	var paths []string
+	for tmpl, config := range templateConfig(file) {
+		src, err := os.ReadFile(filepath.Join(dir, "templates", tmpl+".mustache"))
+		if err != nil {
+			return paths, err
+		}
+		out, err := RenderMustache(string(src), vars)
+		if err != nil {
+			return paths, fmt.Errorf("%s: %w", tmpl, err)
+		}
+
+		outdir := filepath.Join(dir, config["output"])
+		if err := os.MkdirAll(outdir, 0755); err != nil {
+			return paths, err
+		}
+		path := filepath.Join(outdir, "base16-"+vars["scheme-slug"].(string)+config["extension"])
+		if err := os.WriteFile(path, []byte(out), 0644); err != nil {
+			return paths, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// schemeYAML is a scheme with base00 to base(n-1), each a different gray.
+func schemeYAML(system string, n int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "system: %q\nname: \"Test\"\nauthor: \"Someone\"\nvariant: \"dark\"\npalette:\n", system)
+	for slot := 0; slot < n; slot++ {
+		fmt.Fprintf(&b, "  base%02X: \"#%02x%02x%02x\"\n", slot, slot*8, slot*8, slot*8)
+	}
+	return b.String()
+}
+
+func loadScheme(t *testing.T, file string) *ThemeEntry {
+	t.Helper()
+	f, _ := formatByName("yaml")
+	theme, meta, err := parseTheme(f, []byte(file), "test.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newEntry("test", theme, meta, "test.yaml", f.name, nil)
+}
+
+func gray(slot int) color.RGBA {
+	v := uint8(slot * 8)
+	return color.RGBA{v, v, v, 0xff}
+}
+
+func TestBase16(t *testing.T) {
+	e := loadScheme(t, schemeYAML("base16", 16))
+	if e.meta.System != "base16" {
+		t.Errorf("system: got %q", e.meta.System)
+	}
+	for role, c := range e.Base16(e.meta.System) {
+		if c != gray(role) {
+			t.Errorf("base%02X: got %v, want %v", role, c, gray(role))
+		}
+	}
+}
+
+func TestBase16FromBase24(t *testing.T) {
+	e := loadScheme(t, schemeYAML("base24", 24))
+	if e.meta.System != "base24" {
+		t.Errorf("system: got %q", e.meta.System)
+	}
+	base := e.Base16(e.meta.System)
+	for role, c := range base {
+		if role != 0x03 && c != gray(role) {
+			t.Errorf("base%02X: got %v, want %v", role, c, gray(role))
+		}
+	}
+	// base24 has no slot for base03
+	if c := base[0x03]; c.R <= gray(0x02).R || c.R >= gray(0x04).R {
+		t.Errorf("base03: got %v, want between base02 and base04", c)
+	}
+}
+
+// Saving a base24 theme keeps what it takes to undo its slots.
+func TestBase24Saved(t *testing.T) {
+	e := loadScheme(t, schemeYAML("base24", 24))
+	f, _ := formatByName("json")
+	var b bytes.Buffer
+	if err := f.write(&b, e.meta, e.Theme); err != nil {
+		t.Fatal(err)
+	}
+	if m := f.meta(b.Bytes()); m.System != "base24" {
+		t.Errorf("system: got %q, want base24", m.System)
+	}
+}
+
+func TestRenderMustache(t *testing.T) {
+	vars := map[string]any{
+		"name":  "Test",
+		"dark":  true,
+		"light": false,
+		"empty": "",
+		"hex":   "1a2b3c",
+	}
+	for _, tc := range []struct{ tmpl, want string }{
+		{"plain", "plain"},
+		{"{{name}} {{ hex }}", "Test 1a2b3c"},
+		{"{{{name}}}/{{& name}}", "Test/Test"},
+		{"[{{missing}}]", "[]"},
+		{"a{{! a comment\nover lines }}b", "ab"},
+		{"{{#dark}}dark{{/dark}}{{#light}}light{{/light}}", "dark"},
+		{"{{^dark}}not dark{{/dark}}{{^light}}not light{{/light}}", "not light"},
+		{"{{#empty}}x{{/empty}}{{^empty}}y{{/empty}}{{#missing}}z{{/missing}}", "y"},
+		{"{{#dark}}1{{#light}}2{{/light}}3{{/dark}}", "13"},
+		{"{{#light}}1{{^dark}}2{{/dark}}{{name}}{{/light}}", ""},
+		// standalone tags leave no blank lines
+		{"a\n{{#dark}}\nb\n{{/dark}}\nc\n", "a\nb\nc\n"},
+		{"a\n  {{^dark}}  \nb\n\t{{/dark}}\r\nc", "a\nc"},
+		{"{{! comment }}\na\n{{#dark}}\nb\n{{/dark}}", "a\nb\n"},
+		{"a\n{{#dark}}\n  {{#light}}\n  b\n  {{/light}}\n{{/dark}}\n", "a\n"},
+		// but tags sharing a line with text or variables keep it
+		{"a {{#dark}}b{{/dark}}\nc", "a b\nc"},
+		{"{{name}}{{#dark}}\nb\n{{/dark}}", "Test\nb\n"},
+		{"{{#dark}} {{name}}\n{{/dark}}", " Test\n"},
+	} {
+		got, err := RenderMustache(tc.tmpl, vars)
+		if err != nil {
+			t.Errorf("%q: %v", tc.tmpl, err)
+		} else if got != tc.want {
+			t.Errorf("%q: got %q, want %q", tc.tmpl, got, tc.want)
+		}
+	}
+}
+
+func TestRenderMustacheErrors(t *testing.T) {
+	for _, tmpl := range []string{
+		"{{/dark}}",
+		"{{#dark}}",
+		"{{#dark}}{{#light}}{{/dark}}{{/light}}",
+		"{{#dark}}{{/light}}",
+	} {
+		if _, err := RenderMustache(tmpl, map[string]any{"dark": true}); err == nil {
+			t.Errorf("%q: no error", tmpl)
+		}
+	}
+}
+
+func TestRenderTemplates(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		path := filepath.Join(dir, "templates", name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("config.yaml", "default:\n  extension: .conf\n  output: \"out\"\n")
+	write("default.mustache", "{{scheme-name}} {{scheme-system}} {{scheme-slug}} {{base0A-hex}}{{#scheme-is-dark-variant}} dark{{/scheme-is-dark-variant}}\n")
+
+	e := loadScheme(t, schemeYAML("base24", 24))
+	paths, err := RenderTemplates(dir, "My Theme", e.meta, e.Theme)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := filepath.Join(dir, "out", "base16-my-theme.conf")
+	if len(paths) != 1 || paths[0] != want {
+		t.Fatalf("rendered %v, want %s", paths, want)
+	}
+	if out, _ := os.ReadFile(want); string(out) != "Test base24 my-theme 505050 dark\n" {
+		t.Errorf("got %q", out)
+	}
+
+	write("default.mustache", "{{#scheme-is-dark-variant}}")
+	if _, err := RenderTemplates(dir, "My Theme", e.meta, e.Theme); err == nil {
+		t.Error("no error from a broken template")
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"math"
+)
+
+// OKLab is Björn Ottosson's perceptual color space: L is lightness from 0 to
+// 1, and a and b run from green to red and from blue to yellow. Colors are
+// converted through linear sRGB.
+type OKLab struct {
+	L, A, B float64
+}
+
+// OKLCH is OKLab in polar form: C is chroma and H is hue in degrees.
+type OKLCH struct {
+	L, C, H float64
+}
+
+// Ranges of the OKLab properties, scaled to 0..255 for the tweakers. Chroma
+// in sRGB stays below 0.33.
+const (
+	OKLAB_AB = 0.4
+	OKLCH_C  = 0.4
+)
+
+// The sRGB transfer function and its inverse, extended to negative values
+// by symmetry for colors out of gamut.
+func linearize(c float64) float64 {
+	if math.Abs(c) <= 0.04045 {
+		return c / 12.92
+	}
+	return math.Copysign(math.Pow((math.Abs(c)+0.055)/1.055, 2.4), c)
+}
+
+func delinearize(c float64) float64 {
+	if math.Abs(c) <= 0.0031308 {
+		return 12.92 * c
+	}
+	return math.Copysign(1.055*math.Pow(math.Abs(c), 1/2.4)-0.055, c)
+}
+
+func clamp01(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
+
+func toOKLab(c RGB) OKLab {
+	lr, lg, lb := linearize(c.R), linearize(c.G), linearize(c.B)
+
+	l := math.Cbrt(0.4122214708*lr + 0.5363325363*lg + 0.0514459929*lb)
+	m := math.Cbrt(0.2119034982*lr + 0.6806995451*lg + 0.1073969566*lb)
+	s := math.Cbrt(0.0883024619*lr + 0.2817188376*lg + 0.6299787005*lb)
+
+	return OKLab{
+		0.2104542553*l + 0.7936177850*m - 0.0040720468*s,
+		1.9779984951*l - 2.4285922050*m + 0.4505937099*s,
+		0.0259040371*l + 0.7827717662*m - 0.8086757660*s,
+	}
+}
+
+func (c OKLab) rgb() RGB {
+	l := c.L + 0.3963377774*c.A + 0.2158037573*c.B
+	m := c.L - 0.1055613458*c.A - 0.0638541728*c.B
+	s := c.L - 0.0894841775*c.A - 1.2914855480*c.B
+	l, m, s = l*l*l, m*m*m, s*s*s
+
+	r := +4.0767416621*l - 3.3077115913*m + 0.2309699292*s
+	g := -1.2684380046*l + 2.6097574011*m - 0.3413193965*s
+	b := -0.0041960863*l - 0.7034186147*m + 1.7076147010*s
+	return RGB{delinearize(r), delinearize(g), delinearize(b)}
+}
+
+func (c OKLab) RGBA() (uint32, uint32, uint32, uint32) {
+	return c.rgb().RGBA()
+}
+
+func (c OKLab) LCH() OKLCH {
+	h := math.Atan2(c.B, c.A) * 180 / math.Pi
+	if h < 0 {
+		h += 360
+	}
+	return OKLCH{c.L, math.Hypot(c.A, c.B), h}
+}
+
+func (c OKLCH) Lab() OKLab {
+	h := c.H * math.Pi / 180
+	return OKLab{c.L, c.C * math.Cos(h), c.C * math.Sin(h)}
+}
+
+func (c OKLCH) rgb() RGB {
+	return c.Lab().rgb()
+}
+
+func (c OKLCH) RGBA() (uint32, uint32, uint32, uint32) {
+	return c.rgb().RGBA()
+}
+
+// scale maps v from lo..hi onto 0..255, and unscale maps it back.
+func scale(v, lo, hi float64) float64 {
+	return (v - lo) / (hi - lo) * 0xff
+}
+
+func unscale(v, lo, hi float64) float64 {
+	return lo + v/0xff*(hi-lo)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"golang.org/x/term"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	RE_oscReply = regexp.MustCompile(`\x1b\](?:4;(\d+)|(1[01]));(rgb:[0-9A-Fa-f/]+)(?:\x07|\x1b\\)`)
+	RE_da1Reply = regexp.MustCompile(`\x1b\[\?[\d;]*c`)
+)
+
+const QUERY_TIMEOUT = 500 * time.Millisecond
+
+// QueryPalette asks the terminal on the other end of tty for the first n
+// palette colors and the default foreground and background (OSC 4, 10 and
+// 11). Terminals that ignore the queries still answer a DA1 request, which
+// is sent last so its reply marks the end; the timeout is for those that
+// don't answer at all.
+func QueryPalette(tty io.ReadWriter, n int, timeout time.Duration) (Theme, error) {
+	var q strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&q, "\x1b]4;%d;?\x1b\\", i)
+	}
+	q.WriteString("\x1b]10;?\x1b\\\x1b]11;?\x1b\\\x1b[c")
+	if _, err := io.WriteString(tty, q.String()); err != nil {
+		return nil, err
+	}
+
+	chunks := make(chan []byte)
+	done := make(chan bool)
+	defer close(done)
+	go func() {
+		defer close(chunks)
+		for {
+			buf := make([]byte, 1024)
+			k, err := tty.Read(buf)
+			if k > 0 {
+				select {
+				case chunks <- buf[:k]:
+				case <-done:
+					return
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	var replies bytes.Buffer
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+read:
+	for !RE_da1Reply.Match(replies.Bytes()) {
+		select {
+		case chunk, ok := <-chunks:
+			if !ok {
+				break read
+			}
+			replies.Write(chunk)
+		case <-timer.C:
+			break read
+		}
+	}
+
+	palette := make(map[int]string)
+	var fg, bg string
+	for _, m := range RE_oscReply.FindAllSubmatch(replies.Bytes(), -1) {
+		switch string(m[2]) {
+		case "10":
+			fg = string(m[3])
+		case "11":
+			bg = string(m[3])
+		default:
+			index, _ := strconv.Atoi(string(m[1]))
+			palette[index] = string(m[3])
+		}
+	}
+	if len(palette) == 0 {
+		return nil, fmt.Errorf("terminal didn't report its palette")
+	}
+	return paletteColors(palette, fg, bg)
+}
+
+// QueryTerminal queries the controlling terminal, in raw mode so that the
+// replies aren't echoed. The tty stays non-blocking so that closing it
+// stops the reader in QueryPalette rather than leaving it to swallow input,
+// and pending input is flushed before the terminal is restored, so that
+// replies which came after the timeout don't turn into keystrokes.
+func QueryTerminal(n int) (Theme, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer tty.Close()
+
+	conn, err := tty.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+	var state *term.State
+	conn.Control(func(fd uintptr) {
+		state, err = term.MakeRaw(int(fd))
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Control(func(fd uintptr) {
+		flushInput(int(fd))
+		term.Restore(int(fd), state)
+	})
+
+	return QueryPalette(tty, n, QUERY_TIMEOUT)
+}
@@ -0,0 +1,10 @@
+package main
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// flushInput discards whatever the terminal has sent that hasn't been read.
+func flushInput(fd int) error {
+	return unix.IoctlSetInt(fd, unix.TCFLSH, unix.TCIFLUSH)
+}
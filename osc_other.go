@@ -0,0 +1,9 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+
+package main
+
+// Elsewhere pending input is left alone, and a late reply can still reach
+// whatever reads the terminal next.
+func flushInput(fd int) error {
+	return nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/gdamore/tcell/v2"
+	"image/color"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeTTY answers a query with replies, written in the given chunks.
+type fakeTTY struct {
+	r    *io.PipeReader
+	w    *io.PipeWriter
+	sent bytes.Buffer
+}
+
+func newFakeTTY(t *testing.T, chunks ...string) *fakeTTY {
+	r, w := io.Pipe()
+	t.Cleanup(func() { w.Close() })
+	tty := &fakeTTY{r: r, w: w}
+	go func() {
+		for _, chunk := range chunks {
+			if _, err := io.WriteString(w, chunk); err != nil {
+				return
+			}
+		}
+	}()
+	return tty
+}
+
+func (tty *fakeTTY) Read(p []byte) (int, error)  { return tty.r.Read(p) }
+func (tty *fakeTTY) Write(p []byte) (int, error) { return tty.sent.Write(p) }
+
+const DA1 = "\x1b[?62;22c"
+
+// paletteReply is what a terminal with a gray ramp palette would send.
+func paletteReply() string {
+	var b strings.Builder
+	for i := 0; i < 16; i++ {
+		end := "\x1b\\"
+		if i%2 == 1 {
+			end = "\x07"
+		}
+		fmt.Fprintf(&b, "\x1b]4;%d;rgb:%02x%02x/%02x%02x/%02x%02x%s", i, i*16, i*16, i*16, i*16, i*16, i*16, end)
+	}
+	b.WriteString("\x1b]10;rgb:eeee/eeee/eeee\x1b\\")
+	b.WriteString("\x1b]11;rgb:1111/1111/1111\x1b\\")
+	return b.String()
+}
+
+func checkPalette(t *testing.T, theme Theme) {
+	t.Helper()
+	for i := 0; i < 16; i++ {
+		v := uint8(i * 16)
+		if got, want := theme[Black+tcell.Color(i)].RGBA, (color.RGBA{v, v, v, 0xff}); got != want {
+			t.Errorf("color%d: got %v, want %v", i, got, want)
+		}
+	}
+	if got := theme[Foreground].RGBA; got != (color.RGBA{0xee, 0xee, 0xee, 0xff}) {
+		t.Errorf("foreground: got %v", got)
+	}
+	if got := theme[Background].RGBA; got != (color.RGBA{0x11, 0x11, 0x11, 0xff}) {
+		t.Errorf("background: got %v", got)
+	}
+}
+
+func TestQueryPalette(t *testing.T) {
+	tty := newFakeTTY(t, paletteReply()+DA1)
+	theme, err := QueryPalette(tty, 16, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkPalette(t, theme)
+
+	sent := tty.sent.String()
+	if n := strings.Count(sent, "\x1b]4;"); n != 16 {
+		t.Errorf("sent %d palette queries, want 16", n)
+	}
+	if !strings.HasSuffix(sent, "\x1b[c") {
+		t.Errorf("DA1 wasn't sent last: %q", sent)
+	}
+}
+
+// Replies can arrive a few bytes at a time, split anywhere.
+func TestQueryPaletteSplit(t *testing.T) {
+	reply := paletteReply() + DA1
+	var chunks []string
+	for len(reply) > 7 {
+		chunks, reply = append(chunks, reply[:7]), reply[7:]
+	}
+	chunks = append(chunks, reply)
+	theme, err := QueryPalette(newFakeTTY(t, chunks...), 16, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkPalette(t, theme)
+}
+
+// A terminal that ignores OSC queries still answers DA1, which ends the wait.
+func TestQueryPaletteDA1Only(t *testing.T) {
+	start := time.Now()
+	_, err := QueryPalette(newFakeTTY(t, DA1), 16, 10*time.Second)
+	if err == nil {
+		t.Fatal("no error without a palette")
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Error("waited for the timeout despite DA1")
+	}
+}
+
+func TestQueryPaletteTimeout(t *testing.T) {
+	timeout := 50 * time.Millisecond
+	start := time.Now()
+	_, err := QueryPalette(newFakeTTY(t), 16, timeout)
+	if err == nil {
+		t.Fatal("no error without a reply")
+	}
+	if elapsed := time.Since(start); elapsed < timeout {
+		t.Errorf("gave up after %v, before the timeout", elapsed)
+	}
+}
@@ -130,6 +130,7 @@ func (p *Preview) Draw(screen tcell.Screen) {
 		if p.raster == nil {
 			p.raster = new(Raster).Init()
 		}
+	drain:
 		for {
 			select {
 			case s := <-p.sample:
@@ -137,7 +138,7 @@ func (p *Preview) Draw(screen tcell.Screen) {
 			case <-p.raster.done:
 				p.raster.pending = false
 			default:
-				break
+				break drain
 			}
 		}
 		if len(p.samples) > 0 {
@@ -210,4 +211,4 @@ func (p *Preview) drawBars(screen tcell.Screen) *Preview {
 	}
 
 	return p
-}
\ No newline at end of file
+}
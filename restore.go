@@ -0,0 +1,49 @@
+package main
+
+import (
+	"log"
+	"os"
+	"runtime/debug"
+)
+
+// SAVED_PALETTE is the terminal's palette from before any theme was applied,
+// when the terminal could be queried.
+var SAVED_PALETTE Theme
+
+// resetPalette asks the terminal to go back to its configured palette and
+// default colors (OSC 104, 110 and 111).
+func resetPalette() {
+	os.Stderr.WriteString("\x1b]104\x1b\\\x1b]110\x1b\\\x1b]111\x1b\\")
+}
+
+// restorePalette undoes any Theme.Apply. The reset covers slots that
+// SAVED_PALETTE doesn't have and terminals that couldn't be queried;
+// SAVED_PALETTE, when there is one, covers palettes that were set at
+// runtime rather than configured.
+func restorePalette() {
+	resetPalette()
+	SIMULATE = CVD_NONE
+	if SAVED_PALETTE != nil {
+		SAVED_PALETTE.Apply()
+	}
+}
+
+// guard is deferred at the top of main and of the goroutines it starts,
+// since a panic only unwinds its own goroutine. It restores the palette,
+// even with -keep as the palette may be half-applied, and prints where the
+// panic came from before passing it on.
+func guard() {
+	if r := recover(); r != nil {
+		restorePalette()
+		os.Stderr.Write(debug.Stack())
+		panic(r)
+	}
+}
+
+// fatal is log.Fatalln for colorenv, which has to restore the palette
+// before it exits. The message goes to stderr, out from under the preview.
+func fatal(v ...any) {
+	restorePalette()
+	log.SetOutput(os.Stderr)
+	log.Fatalln(v...)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"regexp"
+	"strconv"
+)
+
+var (
+	SCHEME_DIR = os.ExpandEnv("$HOME/.themes/schemes")
+	RE_baseKey = regexp.MustCompile(`^base([0-9A-Fa-f]{2})$`)
+)
+
+// base16-shell's assignment of scheme slots to palette indices: the 16 ANSI
+// colors, then the extra 256-color slots 16..21.
+var b16Slots = []int{
+	0x00, 0x08, 0x0B, 0x0A, 0x0D, 0x0E, 0x0C, 0x05,
+	0x03, 0x08, 0x0B, 0x0A, 0x0D, 0x0E, 0x0C, 0x07,
+	0x09, 0x0F, 0x01, 0x02, 0x04, 0x06,
+}
+
+// base24 gives the bright ANSI colors their own slots (base12..base17)
+// instead of repeating the normal ones.
+var b24Slots = []int{
+	0x00, 0x08, 0x0B, 0x0A, 0x0D, 0x0E, 0x0C, 0x06,
+	0x02, 0x12, 0x14, 0x13, 0x16, 0x17, 0x15, 0x07,
+	0x09, 0x0F, 0x01, 0x02, 0x04, 0x06,
+}
+
+// Scheme is a base16 or base24 scheme as distributed in YAML.
+type Scheme struct {
+	name    string
+	author  string
+	system  string
+	variant string
+	base    map[int]string
+}
+
+// Both the classic layout (scheme/author/baseXX at the top level) and the
+// newer one (name/author/palette.baseXX) are understood. Other keys are
+// passed over.
+func parseSchemeYAML(file []byte) (*Scheme, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(file, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("scheme is not a mapping")
+	}
+	s := &Scheme{base: make(map[int]string)}
+	if err := s.scan(doc.Content[0], true); err != nil {
+		return nil, err
+	}
+
+	// older base24 schemes don't declare a system
+	if s.system == "" {
+		s.system = "base16"
+		if _, found := s.base[0x17]; found {
+			s.system = "base24"
+		}
+	}
+
+	n := 0x10
+	switch s.system {
+	case "base16":
+	case "base24":
+		n = 0x18
+	default:
+		return nil, fmt.Errorf("unknown scheme system %q", s.system)
+	}
+	for slot := 0; slot < n; slot++ {
+		if _, found := s.base[slot]; !found {
+			return nil, fmt.Errorf("scheme missing base%02X", slot)
+		}
+	}
+	return s, nil
+}
+
+// scan reads the keys of a scheme's mapping: the top level, or its palette.
+func (s *Scheme) scan(m *yaml.Node, top bool) error {
+	fields := map[string]*string{
+		"scheme":  &s.name,
+		"name":    &s.name,
+		"author":  &s.author,
+		"system":  &s.system,
+		"variant": &s.variant,
+	}
+
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		key, val := m.Content[i].Value, m.Content[i+1]
+		match := RE_baseKey.FindStringSubmatch(key)
+		field, found := fields[key]
+		switch {
+		case top && key == "palette":
+			if val.Kind != yaml.MappingNode {
+				return &ParseError{Line: val.Line, Err: fmt.Errorf("palette is not a mapping")}
+			}
+			if err := s.scan(val, false); err != nil {
+				return err
+			}
+			continue
+		case len(match) < 2 && !(top && found):
+			continue
+		case val.Kind != yaml.ScalarNode:
+			return &ParseError{Line: val.Line, Err: fmt.Errorf("%s is not a string", key)}
+		}
+
+		if len(match) < 2 {
+			*field = val.Value
+			continue
+		}
+		if _, err := FromHex(val.Value); err != nil {
+			return &ParseError{Line: val.Line, Err: err}
+		}
+		slot, _ := strconv.ParseUint(match[1], 16, 8)
+		s.base[int(slot)] = val.Value
+	}
+	return nil
+}
+
+func (s *Scheme) slots() []int {
+	return systemSlots(s.system)
+}
+
+// systemSlots is the slot assignment for a scheme system.
+func systemSlots(system string) []int {
+	if system == "base24" {
+		return b24Slots
+	}
+	return b16Slots
+}
+
+func (s *Scheme) Theme() (Theme, error) {
+	slots := s.slots()
+	t := make([]string, len(slots))
+	for i, slot := range slots {
+		t[i] = s.base[slot]
+	}
+	return make(Theme).From(t, s.base[0x05], s.base[0x00])
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gdamore/tcell/v2"
+	"strings"
+	"testing"
+)
+
+func TestSchemeLayouts(t *testing.T) {
+	newer := schemeYAML("base16", 16)
+	var classic strings.Builder
+	classic.WriteString("scheme: Test # a comment\nauthor: 'Someone'\nslug: test\n")
+	for slot := 0; slot < 16; slot++ {
+		// unquoted, and one that reads as a number
+		fmt.Fprintf(&classic, "base%02X: %02x%02x%02x\n", slot, slot*8, slot*8, slot*8)
+	}
+	flow := "name: Test\nauthor: Someone\npalette: {" + strings.Join(strings.Split(
+		strings.TrimSpace(strings.SplitN(newer, "palette:\n", 2)[1]), "\n"), ",") + "}\n"
+
+	for _, file := range []string{newer, classic.String(), flow} {
+		s, err := parseSchemeYAML([]byte(file))
+		if err != nil {
+			t.Fatalf("%v\n%s", err, file)
+		}
+		if s.name != "Test" || s.author != "Someone" || s.system != "base16" {
+			t.Errorf("got %q by %q, %s\n%s", s.name, s.author, s.system, file)
+		}
+		theme, err := s.Theme()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for role, c := range theme.Base16(s.system) {
+			if c != gray(role) {
+				t.Errorf("base%02X: got %v, want %v\n%s", role, c, gray(role), file)
+			}
+		}
+	}
+}
+
+func TestSchemeMalformed(t *testing.T) {
+	file := schemeYAML("base16", 16)
+	for _, tc := range []struct {
+		file string
+		line int
+	}{
+		{"", 0},
+		{"- base00: '000000'\n", 0},
+		{"palette:\n  base00: [1, 2\n", 0},
+		{"name: Test\npalette:\n  - '#000000'\n", 3},
+		{strings.Replace(file, `base03: "#181818"`, "base03: [0x18, 0x18, 0x18]", 1), 9},
+		{strings.Replace(file, `base03: "#181818"`, `base03: "#1818"`, 1), 9},
+		{strings.Replace(file, `name: "Test"`, "name: {first: Test}", 1), 2},
+		{strings.Replace(file, `  base0F: "#787878"`+"\n", "", 1), 0},
+		{schemeYAML("base32", 16), 0},
+	} {
+		_, err := parseSchemeYAML([]byte(tc.file))
+		var perr *ParseError
+		switch {
+		case err == nil:
+			t.Errorf("no error reading %q", tc.file)
+		case tc.line > 0 && (!errors.As(err, &perr) || perr.Line != tc.line):
+			t.Errorf("got %v, want an error at line %d", err, tc.line)
+		}
+	}
+}
+
+// A base24 scheme lands in the palette the way base24-shell puts it: its
+// own bright colors, and base06/base02 for white and bright black.
+func TestBase24Slots(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("system: base24\nname: Test\npalette:\n")
+	for slot := 0; slot < 24; slot++ {
+		// each slot's own number, in red
+		fmt.Fprintf(&b, "  base%02X: \"%02x0000\"\n", slot, slot)
+	}
+	s, err := parseSchemeYAML([]byte(b.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	theme, err := s.Theme()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for c, slot := range map[tcell.Color]uint8{
+		Black: 0x00, Red: 0x08, Green: 0x0B, Yellow: 0x0A,
+		Blue: 0x0D, Magenta: 0x0E, Cyan: 0x0C, White: 0x06,
+		BrightBlack: 0x02, BrightRed: 0x12, BrightGreen: 0x14, BrightYellow: 0x13,
+		BrightBlue: 0x16, BrightMagenta: 0x17, BrightCyan: 0x15, BrightWhite: 0x07,
+		Black + 16: 0x09, Black + 17: 0x0F, Black + 18: 0x01,
+		Black + 19: 0x02, Black + 20: 0x04, Black + 21: 0x06,
+		Foreground: 0x05, Background: 0x00,
+	} {
+		if got := theme[c].R; got != slot {
+			t.Errorf("%s: got base%02X, want base%02X", slotName(c), got, slot)
+		}
+	}
+	if n := len(theme.Slots()); n != len(b24Slots) {
+		t.Errorf("%d palette slots, want %d", n, len(b24Slots))
+	}
+}
@@ -1,36 +1,381 @@
 package main
 
 import (
-	//"fmt"
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"image/color"
+	"io"
+	"io/fs"
 	"log"
 	"os"
+	"path/filepath"
 	"regexp"
+	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 )
 
+// A few well-known schemes, so there's something to work with on a machine
+// without any themes installed.
+//
+//go:embed themes
+var builtinThemes embed.FS
+
 var (
 	B16_DIR      = os.ExpandEnv("$HOME/.themes/shell/scripts")
+	THEME_DIRS   []string
 	RE_b16name   = regexp.MustCompile(`base16-(.+)\.sh`)
 	RE_shellAssn = regexp.MustCompile(`(?m)^color_?\w+=\S+`)
 	RE_colorkey  = regexp.MustCompile(`\bcolor_?(\d+|foreground|background)\b`)
+	RE_confPath  = regexp.MustCompile(`^\s*path\s*=\s*(.*\S)`)
+	RE_shellMeta = regexp.MustCompile(`(?m)^# (.+?) scheme(?: by (.+?))?(?:, (base\d+))?(?:, saved by colorenv)?$`)
+	RE_headMeta  = regexp.MustCompile(`(?m)^(?:#|!|<!--) (.+?)(?: by (.+?))?(?:, (base\d+))?, exported by colorenv\b`)
 )
 
-type ThemeMap map[string]Theme
+// Meta is what a theme says about itself besides its colors. Formats that
+// don't record something leave it empty.
+type Meta struct {
+	Name    string
+	Author  string
+	Variant string
+	// System is the scheme system the theme's slots were assigned by, for
+	// themes that came from a scheme or were saved from one.
+	System string
+	// Cursor is the cursor and selection colors, for formats that set them
+	// apart from the palette.
+	Cursor *Cursor
+}
 
-func allThemes() ThemeMap {
-	ents, err := os.ReadDir(B16_DIR)
+// Cursor colors are "#rrggbb". Empty ones follow the foreground and
+// background.
+type Cursor struct {
+	Color       string `json:"color,omitempty"`
+	Text        string `json:"text,omitempty"`
+	SelectionFg string `json:"selection_fg,omitempty"`
+	SelectionBg string `json:"selection_bg,omitempty"`
+}
+
+func (m Meta) String() string {
+	if m.Author == "" {
+		return m.Name
+	}
+	return m.Name + " by " + m.Author
+}
+
+// header is the comment Export starts files with, for headerMeta to read
+// back: who the theme is by, and the scheme system its slots follow.
+func (m Meta) header() string {
+	if m.System != "" {
+		return fmt.Sprintf("%s, %s, exported by colorenv", m, m.System)
+	}
+	return fmt.Sprintf("%s, exported by colorenv", m)
+}
+
+// ThemeEntry is a theme in the library, what it says about itself, and the
+// file and format it came from. A theme that couldn't be loaded has err set
+// and no colors.
+type ThemeEntry struct {
+	Theme
+	meta   Meta
+	path   string
+	format string
+	err    error
+}
+
+// newEntry fills in what meta leaves out: the display name defaults to the
+// theme's name in the library, and the variant is worked out from the
+// background.
+func newEntry(name string, t Theme, meta Meta, path, format string, err error) *ThemeEntry {
+	if meta.Name == "" {
+		meta.Name = name
+	}
+	if meta.Variant == "" && t != nil {
+		meta.Variant = t.Variant()
+	}
+	return &ThemeEntry{Theme: t, meta: meta, path: path, format: format, err: err}
+}
+
+var (
+	ErrBadColor   = errors.New("bad color")
+	ErrKeyOrder   = errors.New("theme keys out of order")
+	ErrUnknownKey = errors.New("unknown key")
+)
+
+// ParseError is a problem loading a theme file, at a line if it's known.
+type ParseError struct {
+	File string
+	Line int
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	switch {
+	case e.File != "" && e.Line > 0:
+		return fmt.Sprintf("%s:%d: %v", e.File, e.Line, e.Err)
+	case e.File != "":
+		return fmt.Sprintf("%s: %v", e.File, e.Err)
+	case e.Line > 0:
+		return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+	}
+	return e.Err.Error()
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+func lineAt(file []byte, offset int) int {
+	return bytes.Count(file[:offset], []byte("\n")) + 1
+}
+
+type ThemeMap map[string]*ThemeEntry
+
+func xdgDir(env, fallback string) string {
+	if dir := os.Getenv(env); dir != "" {
+		return dir
+	}
+	return os.ExpandEnv(fallback)
+}
+
+// DataDir is where saved themes go. It's on the search path.
+func DataDir() string {
+	return filepath.Join(xdgDir("XDG_DATA_HOME", "$HOME/.local/share"), "cx", "themes")
+}
+
+// configPath reads the path entries of the config file.
+func configPath() []string {
+	conf := filepath.Join(xdgDir("XDG_CONFIG_HOME", "$HOME/.config"), "cx", "config")
+	file, err := os.ReadFile(conf)
 	if err != nil {
-		log.Fatalln(err)
+		return nil
 	}
 
-	tm := make(ThemeMap)
-	for _, ent := range ents {
-		if match := RE_b16name.FindStringSubmatch(ent.Name()); len(match) > 1 {
-			theme := match[1]
-			tm[theme] = parseTheme(theme)
+	var dirs []string
+	for _, line := range strings.Split(string(file), "\n") {
+		if match := RE_confPath.FindStringSubmatch(line); len(match) > 1 {
+			dirs = append(dirs, filepath.SplitList(match[1])...)
 		}
 	}
+	return dirs
+}
+
+// ThemePath lists the directories themes are loaded from, highest priority
+// first: -dir arguments, $CX_THEME_PATH, path entries in the config file,
+// the XDG data directories, and finally the base16-shell and scheme
+// directories.
+func ThemePath() []string {
+	var path []string
+	path = append(path, THEME_DIRS...)
+	path = append(path, filepath.SplitList(os.Getenv("CX_THEME_PATH"))...)
+	path = append(path, configPath()...)
+
+	path = append(path, DataDir())
+	for _, dir := range filepath.SplitList(xdgDir("XDG_DATA_DIRS", "/usr/local/share:/usr/share")) {
+		path = append(path, filepath.Join(dir, "cx", "themes"))
+	}
+	path = append(path, B16_DIR, SCHEME_DIR)
+
+	for i, dir := range path {
+		if strings.HasPrefix(dir, "~/") {
+			dir = "$HOME" + dir[1:]
+		}
+		path[i] = os.ExpandEnv(dir)
+	}
+	return path
+}
+
+// libraryFormat is the format of a file in a theme directory, if it's a
+// theme: a base16-shell script, a native theme or a scheme.
+func libraryFormat(fname string) (*Format, bool) {
+	f, ok := formatByPath(fname)
+	if !ok {
+		return nil, false
+	}
+	switch f.name {
+	case "shell":
+		return f, RE_b16name.MatchString(fname)
+	case "json", "yaml":
+		return f, true
+	}
+	return nil, false
+}
+
+func parseTheme(f *Format, file []byte, path string) (Theme, Meta, error) {
+	t, err := f.read(file)
+	if perr, ok := err.(*ParseError); ok {
+		perr.File = path
+	} else if err != nil {
+		err = &ParseError{File: path, Err: err}
+	}
+	var meta Meta
+	if err == nil && f.meta != nil {
+		meta = f.meta(file)
+	}
+	return t, meta, err
+}
+
+func loadTheme(f *Format, path string) (Theme, Meta, error) {
+	file, err := os.ReadFile(path)
+	if err != nil {
+		return nil, Meta{}, err
+	}
+	return parseTheme(f, file, path)
+}
+
+// themeFile is a theme found on the search path, not yet loaded.
+type themeFile struct {
+	name  string
+	path  string
+	f     *Format
+	fsys  fs.FS
+	fname string
+	info  fs.FileInfo
+}
+
+func (tf *themeFile) load() *ThemeEntry {
+	file, err := fs.ReadFile(tf.fsys, tf.fname)
+	if err != nil {
+		return newEntry(tf.name, nil, Meta{}, tf.path, tf.f.name, err)
+	}
+	theme, meta, err := parseTheme(tf.f, file, tf.path)
+	return newEntry(tf.name, theme, meta, tf.path, tf.f.name, err)
+}
+
+// findThemes lists the themes on the search path, then the builtin ones.
+// Where names collide, the directory earlier in the path wins, and within a
+// directory a native theme wins, so a saved theme isn't hidden by its own
+// export. Directories that don't exist are skipped.
+func findThemes() []*themeFile {
+	var files []*themeFile
+	seen := make(map[string]bool)
+
+	scan := func(fsys fs.FS, root string) error {
+		ents, err := fs.ReadDir(fsys, ".")
+		if err != nil {
+			return err
+		}
+		here := make(map[string]int)
+		for _, ent := range ents {
+			f, ok := libraryFormat(ent.Name())
+			name := importName(ent.Name())
+			if !ok {
+				continue
+			}
+			info, _ := ent.Info()
+			path := filepath.Join(root, ent.Name())
+			tf := &themeFile{name, path, f, fsys, ent.Name(), info}
+
+			if i, found := here[name]; found {
+				if f.name == "json" {
+					files[i] = tf
+				}
+				continue
+			}
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			here[name] = len(files)
+			files = append(files, tf)
+		}
+		return nil
+	}
+
+	for _, dir := range ThemePath() {
+		err := scan(os.DirFS(dir), dir)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			log.Println(err)
+		}
+	}
+	builtin, _ := fs.Sub(builtinThemes, "themes")
+	scan(builtin, "builtin")
+	return files
+}
+
+// loadThemes loads files over several goroutines, taking unchanged files
+// from the cache. found is called from those goroutines as each theme is
+// ready; themes that fail to load come with their error.
+func loadThemes(files []*themeFile, found func(string, *ThemeEntry)) {
+	cache := readCache()
+	fresh := newCache()
+	jobs := make(chan *themeFile)
+
+	var wg sync.WaitGroup
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer guard()
+			for tf := range jobs {
+				e, hit := cache.lookup(tf)
+				if !hit {
+					e = tf.load()
+				}
+				fresh.store(tf, e)
+				found(tf.name, e)
+			}
+		}()
+	}
+	for _, tf := range files {
+		jobs <- tf
+	}
+	close(jobs)
+	wg.Wait()
+
+	if err := fresh.write(); err != nil {
+		log.Println("couldn't write theme cache", err)
+	}
+}
+
+// LOAD_BATCH is the most themes loadBatches hands over at once.
+const LOAD_BATCH = 64
+
+// loadBatches is loadThemes for the UI: found gets whatever has loaded since
+// its last call, up to LOAD_BATCH themes, and is only called from one
+// goroutine. It returns after the last batch.
+func loadBatches(files []*themeFile, found func(ThemeMap)) {
+	type loaded struct {
+		name string
+		e    *ThemeEntry
+	}
+	ready := make(chan loaded, LOAD_BATCH)
+	go func() {
+		loadThemes(files, func(tname string, e *ThemeEntry) {
+			ready <- loaded{tname, e}
+		})
+		close(ready)
+	}()
+
+	for l := range ready {
+		batch := ThemeMap{l.name: l.e}
+	drain:
+		for len(batch) < LOAD_BATCH {
+			select {
+			case l, ok := <-ready:
+				if !ok {
+					break drain
+				}
+				batch[l.name] = l.e
+			default:
+				break drain
+			}
+		}
+		found(batch)
+	}
+}
+
+// allThemes loads every theme on the search path and waits for them.
+func allThemes() ThemeMap {
+	tm := make(ThemeMap)
+	var mu sync.Mutex
+	loadThemes(findThemes(), func(name string, e *ThemeEntry) {
+		mu.Lock()
+		defer mu.Unlock()
+		tm[name] = e
+	})
 	return tm
 }
 
@@ -43,23 +388,26 @@ func shellKeyIndex(k string) (int, bool) {
 	return 0, false
 }
 
-func parseTheme(theme string) Theme {
-	file, err := os.ReadFile(B16_DIR + "/base16-" + theme + ".sh")
-	if err != nil {
-		log.Fatalln("couldn't read theme file", theme)
-	}
-
+func parseShell(file []byte) (Theme, error) {
 	var t []string
 	var fg, bg string
 
-	for _, assn := range RE_shellAssn.FindAll(file, -1) {
-		key, val, _ := strings.Cut(string(assn), "=")
+	for _, loc := range RE_shellAssn.FindAllIndex(file, -1) {
+		line := lineAt(file, loc[0])
+		key, val, _ := strings.Cut(string(file[loc[0]:loc[1]]), "=")
 		if index, found := shellKeyIndex(val); found {
+			if index >= len(t) {
+				return nil, &ParseError{Line: line, Err: fmt.Errorf("%s refers ahead to %s", key, val)}
+			}
 			val = t[index]
 		}
+		if _, err := FromHex(val); err != nil {
+			return nil, &ParseError{Line: line, Err: err}
+		}
+
 		if index, found := shellKeyIndex(key); found {
 			if index != len(t) {
-				panic("theme keys out of order")
+				return nil, &ParseError{Line: line, Err: ErrKeyOrder}
 			}
 			t = append(t, val)
 		} else if key == "color_foreground" {
@@ -67,17 +415,154 @@ func parseTheme(theme string) Theme {
 		} else if key == "color_background" {
 			bg = val
 		} else {
-			log.Fatalln("couldn't parse key", key)
+			return nil, &ParseError{Line: line, Err: fmt.Errorf("%w %s", ErrUnknownKey, key)}
 		}
 	}
 
 	return make(Theme).From(t, fg, bg)
 }
 
-func (tm ThemeMap) Apply(theme string) Theme {
-	t, found := tm[theme]
+// shellMeta reads the "# <name> scheme by <author>" line of a base16-shell
+// script, and the scheme system of ones colorenv saved.
+func shellMeta(file []byte) Meta {
+	var m Meta
+	if match := RE_shellMeta.FindSubmatch(file); match != nil {
+		m.Name, m.Author, m.System = string(match[1]), string(match[2]), string(match[3])
+	}
+	return m
+}
+
+// headerMeta reads the comment that Export starts files with, for formats
+// that have nowhere else to say who a theme is by.
+func headerMeta(file []byte) Meta {
+	var m Meta
+	if match := RE_headMeta.FindSubmatch(file); match != nil {
+		m.Name, m.Author, m.System = string(match[1]), string(match[2]), string(match[3])
+	}
+	return m
+}
+
+// Apply applies the named theme. A theme that couldn't be loaded gives the
+// reason why.
+func (tm ThemeMap) Apply(theme string) (Theme, error) {
+	e, found := tm[theme]
 	if !found {
-		log.Fatalln("nonexistant theme", theme)
+		return nil, fmt.Errorf("no theme %q", theme)
+	}
+	if e.err != nil {
+		return nil, e.err
+	}
+	return e.Apply(), nil
+}
+
+// The escape-sequence body of a base16-shell script, after the color
+// assignments. Kept in step with upstream's template so that saved themes
+// behave like any other script in B16_DIR.
+const shellBody = `
+if [ -n "$TMUX" ]; then
+  # Tell tmux to pass the escape sequences through
+  put_template() { printf '\033Ptmux;\033\033]4;%d;rgb:%s\033\033\\\033\\' $@; }
+  put_template_var() { printf '\033Ptmux;\033\033]%d;rgb:%s\033\033\\\033\\' $@; }
+  put_template_custom() { printf '\033Ptmux;\033\033]%s%s\033\033\\\033\\' $@; }
+elif [ "${TERM%%[-.]*}" = "screen" ]; then
+  # GNU screen (screen, screen-256color, screen-256color-bce)
+  put_template() { printf '\033P\033]4;%d;rgb:%s\007\033\\' $@; }
+  put_template_var() { printf '\033P\033]%d;rgb:%s\007\033\\' $@; }
+  put_template_custom() { printf '\033P\033]%s%s\007\033\\' $@; }
+elif [ "${TERM%%-*}" = "linux" ]; then
+  put_template() { [ $1 -lt 16 ] && printf "\e]P%x%s" $1 $(echo $2 | sed 's/\///g'); }
+  put_template_var() { true; }
+  put_template_custom() { true; }
+else
+  put_template() { printf '\033]4;%d;rgb:%s\033\\' $@; }
+  put_template_var() { printf '\033]%d;rgb:%s\033\\' $@; }
+  put_template_custom() { printf '\033]%s%s\033\\' $@; }
+fi
+
+`
+
+func shellHex(c color.RGBA) string {
+	return fmt.Sprintf("%02x/%02x/%02x", c.R, c.G, c.B)
+}
+
+// WriteShell writes t as a base16-shell script, in the layout parseTheme
+// reads back.
+func (t Theme) WriteShell(w io.Writer, m Meta) error {
+	var b strings.Builder
+	slots := t.Slots()
+
+	fmt.Fprintf(&b, "#!/bin/sh\n# base16-shell (https://github.com/chriskempson/base16-shell)\n")
+	fmt.Fprintf(&b, "# %s scheme", m.Name)
+	if m.Author != "" {
+		fmt.Fprintf(&b, " by %s", m.Author)
+	}
+	if m.System != "" {
+		fmt.Fprintf(&b, ", %s", m.System)
+	}
+	b.WriteString(", saved by colorenv\n\n")
+
+	for i, c := range slots {
+		fmt.Fprintf(&b, "color%02d=\"%s\"", i, shellHex(t[c].RGBA))
+		if name, found := cNames[c]; found {
+			fmt.Fprintf(&b, " # %s", name.string)
+		}
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "color_foreground=\"%s\"\n", shellHex(t[Foreground].RGBA))
+	fmt.Fprintf(&b, "color_background=\"%s\"\n", shellHex(t[Background].RGBA))
+
+	b.WriteString(shellBody)
+	for i := range slots {
+		fmt.Fprintf(&b, "put_template %d $color%02d\n", i, i)
+	}
+	b.WriteString(`
+# foreground / background / cursor color
+if [ -n "$ITERM_SESSION_ID" ]; then
+  # iTerm2 proprietary escape codes
+  put_template_custom Pg $(echo $color_foreground | sed 's/\///g') # foreground
+  put_template_custom Ph $(echo $color_background | sed 's/\///g') # background
+  put_template_custom Pi $(echo $color_foreground | sed 's/\///g') # bold color
+  put_template_custom Pj $(echo $color_background | sed 's/\///g') # selection color
+  put_template_custom Pk $(echo $color_foreground | sed 's/\///g') # selected text color
+  put_template_custom Pl $(echo $color_foreground | sed 's/\///g') # cursor
+  put_template_custom Pm $(echo $color_background | sed 's/\///g') # cursor text
+else
+  put_template_var 10 $color_foreground
+  if [ "$BASE16_SHELL_SET_BACKGROUND" != false ]; then
+    put_template_var 11 $color_background
+    if [ "${TERM%%-*}" = "rxvt" ]; then
+      put_template_var 708 $color_background # internal border (rxvt)
+    fi
+  fi
+  put_template_custom 12 ";7" # cursor (reverse video)
+fi
+
+# clean up
+unset -f put_template
+unset -f put_template_var
+unset -f put_template_custom
+`)
+	for i := range slots {
+		fmt.Fprintf(&b, "unset color%02d\n", i)
+	}
+	b.WriteString("unset color_foreground\nunset color_background\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeFile(path string, write func(io.Writer) error) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	err = write(file)
+	if cerr := file.Close(); err == nil {
+		err = cerr
 	}
-	return t.Apply()
+	return err
 }
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// isolate points every theme directory at an empty temporary one.
+func isolate(t *testing.T) {
+	home := t.TempDir()
+	for _, env := range []string{"HOME", "XDG_DATA_HOME", "XDG_CONFIG_HOME", "XDG_DATA_DIRS", "XDG_CACHE_HOME"} {
+		t.Setenv(env, home)
+	}
+	t.Setenv("CX_THEME_PATH", "")
+	dirs := THEME_DIRS
+	t.Cleanup(func() { THEME_DIRS = dirs })
+}
+
+func touch(t *testing.T, dir, fname string) {
+	if err := os.WriteFile(filepath.Join(dir, fname), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// put writes theme to dir/fname in the named format.
+func put(t *testing.T, dir, fname, format string, theme Theme) {
+	t.Helper()
+	f, _ := formatByName(format)
+	var b bytes.Buffer
+	if err := f.write(&b, Meta{Name: fname}, theme); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, fname), b.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAllThemesPrecedence(t *testing.T) {
+	isolate(t)
+	first, second := t.TempDir(), t.TempDir()
+	THEME_DIRS = []string{first, second}
+
+	// the export sorts before the saved theme, but mustn't hide it
+	put(t, first, "base16-foo-cx.sh", "shell", sampleTheme())
+	put(t, first, "foo-cx.json", "json", sampleTheme())
+	// the earlier directory wins, whatever the format
+	put(t, first, "base16-bar.sh", "shell", sampleTheme())
+	put(t, second, "bar.json", "json", sampleTheme())
+
+	tm := allThemes()
+	if e := tm["foo-cx"]; e == nil || e.path != filepath.Join(first, "foo-cx.json") {
+		t.Errorf("foo-cx: got %+v, want the json theme", e)
+	}
+	if e := tm["bar"]; e == nil || e.path != filepath.Join(first, "base16-bar.sh") {
+		t.Errorf("bar: got %+v, want the first directory's", e)
+	}
+}
+
+func TestAllThemesSystem(t *testing.T) {
+	isolate(t)
+	dir := t.TempDir()
+	THEME_DIRS = []string{dir}
+	if err := os.WriteFile(filepath.Join(dir, "wide.yaml"), []byte(schemeYAML("base24", 24)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	put(t, dir, "base16-plain.sh", "shell", sampleTheme())
+
+	// the second time round, from the cache
+	for i := 0; i < 2; i++ {
+		tm := allThemes()
+		if e := tm["wide"]; e == nil || e.meta.System != "base24" {
+			t.Errorf("wide: got %+v, want a base24 entry", e)
+		}
+		if e := tm["plain"]; e == nil || e.meta.System != "" {
+			t.Errorf("plain: got %+v, want no system", e)
+		}
+	}
+}
+
+// With nothing installed, every builtin theme loads.
+func TestBuiltinThemes(t *testing.T) {
+	isolate(t)
+	THEME_DIRS = nil
+	ents, err := builtinThemes.ReadDir("themes")
+	if err != nil || len(ents) == 0 {
+		t.Fatalf("no builtin themes: %v", err)
+	}
+
+	tm := allThemes()
+	for _, ent := range ents {
+		name := importName(ent.Name())
+		e := tm[name]
+		switch {
+		case e == nil:
+			t.Errorf("%s: not loaded", name)
+		case e.err != nil:
+			t.Errorf("%s: %v", name, e.err)
+		case e.path != filepath.Join("builtin", ent.Name()):
+			t.Errorf("%s: loaded from %s", name, e.path)
+		case len(e.Slots()) < 16:
+			t.Errorf("%s: only %d colors", name, len(e.Slots()))
+		}
+	}
+	if len(tm) != len(ents) {
+		t.Errorf("loaded %d themes, want the %d builtin ones", len(tm), len(ents))
+	}
+}
+
+func TestShellErrors(t *testing.T) {
+	var b bytes.Buffer
+	if err := sampleTheme().WriteShell(&b, Meta{Name: "Sample"}); err != nil {
+		t.Fatal(err)
+	}
+	file := b.String()
+	red := shellHex(sampleTheme()[Red].RGBA)
+	lineOf := func(s string) int {
+		return strings.Count(file[:strings.Index(file, s)], "\n") + 1
+	}
+
+	for _, tc := range []struct {
+		from, to string
+		want     error
+	}{
+		{`color01="` + red, `color01="zz/zz/zz`, ErrBadColor},
+		{`color01="` + red, `color01="` + red[:5], ErrBadColor},
+		{`color01="`, `color02="`, ErrKeyOrder},
+		{`color_foreground=`, `color_cursor=`, ErrUnknownKey},
+		{`color01="` + red, `color01="$color05`, nil},
+	} {
+		_, err := parseShell([]byte(strings.Replace(file, tc.from, tc.to, 1)))
+		var perr *ParseError
+		switch {
+		case !errors.As(err, &perr):
+			t.Errorf("%s: got %v, want a ParseError", tc.to, err)
+		case perr.Line != lineOf(tc.from):
+			t.Errorf("%s: error on line %d, want %d", tc.to, perr.Line, lineOf(tc.from))
+		case tc.want != nil && !errors.Is(err, tc.want):
+			t.Errorf("%s: got %v, want %v", tc.to, err, tc.want)
+		}
+	}
+}
+
+// Broken and unknown themes aren't applied, and say why.
+func TestThemeMapApply(t *testing.T) {
+	broken := fmt.Errorf("broken")
+	tm := ThemeMap{"broken": &ThemeEntry{err: broken}}
+	if _, err := tm.Apply("broken"); err != broken {
+		t.Errorf("broken theme: got %v", err)
+	}
+	if _, err := tm.Apply("missing"); err == nil {
+		t.Error("missing theme: no error")
+	}
+}
@@ -1,10 +1,12 @@
 package main
 
 import (
+	"fmt"
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
 	//"log"
 	"sort"
+	"strings"
 )
 
 type Themer struct {
@@ -13,6 +15,8 @@ type Themer struct {
 	tm       ThemeMap
 	list     *tview.List
 	refList  *tview.List
+	names    []string
+	status   *tview.TextView
 	search   *tview.InputField
 	Width    int
 	doneFunc func(string)
@@ -28,30 +32,35 @@ func NewThemer() *Themer {
 
 	search := tview.NewInputField().
 		SetFieldBackgroundColor(list.GetBackgroundColor())
+	status := tview.NewTextView().
+		SetDynamicColors(true)
 
 	flex := tview.NewFlex().
 		AddItem(list, 0, 1, false).
+		AddItem(status, 0, 0, false).
 		AddItem(search, 2, 0, true).
 		SetDirection(tview.FlexRow)
 
 	search.SetBorderPadding(1, 0, 0, 0)
 	flex.SetBorderPadding(1, 1, 1, 1)
 
-	t := &Themer{Flex: flex, list: list, refList: refList, search: search}
+	t := &Themer{Flex: flex, list: list, refList: refList, status: status, search: search}
 
-	search.SetChangedFunc(func(text string) {
-		if text == "" {
-			t.resetList(nil)
-		} else if matches := refList.FindItems(text, "", false, true); matches != nil {
-			t.resetList(matches)
-		} else {
-			t.resetList([]int{})
-		}
-	})
+	search.SetChangedFunc(t.filter)
 
 	return t
 }
 
+func (t *Themer) filter(text string) {
+	if text == "" {
+		t.resetList(nil)
+	} else if matches := t.refList.FindItems(text, "", false, true); matches != nil {
+		t.resetList(matches)
+	} else {
+		t.resetList([]int{})
+	}
+}
+
 func simpleAdd(list *tview.List, theme string) {
 	list.AddItem(theme, "", 0, nil)
 }
@@ -78,33 +87,170 @@ func (t *Themer) init(tm ThemeMap) *Themer {
 	return t
 }
 
+// Expect makes room for themes that are still to be added.
+func (t *Themer) Expect(tnames []string) *Themer {
+	for _, tname := range tnames {
+		if len(tname) > t.Width {
+			t.Width = len(tname)
+		}
+	}
+	return t
+}
+
+// Add puts a theme into the list, or replaces it if it's already there. The
+// search isn't redone: the theme goes straight to its place among the
+// matches, and the current theme stays selected.
+func (t *Themer) Add(tname string, e *ThemeEntry) {
+	_, found := t.tm[tname]
+	t.tm[tname] = e
+	t.Expect([]string{tname})
+
+	i := sort.SearchStrings(t.names, tname)
+	listed := i < len(t.names) && t.names[i] == tname
+	switch {
+	case listed:
+		t.list.SetItemText(i, t.itemText(tname), "")
+	case !found && t.matches(tname):
+		t.list.InsertItem(i, t.itemText(tname), "", 0, nil)
+		t.names = append(t.names, "")
+		copy(t.names[i+1:], t.names[i:])
+		t.names[i] = tname
+	}
+	if !found {
+		count := t.refList.GetItemCount()
+		i := sort.Search(count, func(i int) bool {
+			name, _ := t.refList.GetItemText(i)
+			return name >= tname
+		})
+		t.refList.InsertItem(i, tname, "", 0, nil)
+	}
+	t.showStatus()
+}
+
+// matches is whether a theme belongs in the list for the current search,
+// the same way filter finds them.
+func (t *Themer) matches(tname string) bool {
+	text := strings.ToLower(t.search.GetText())
+	return text == "" || strings.Contains(strings.ToLower(tname), text)
+}
+
+// Remove takes a theme out of the list.
+func (t *Themer) Remove(tname string) {
+	if _, found := t.tm[tname]; !found {
+		return
+	}
+	delete(t.tm, tname)
+	for _, i := range t.refList.FindItems(tname, "", false, false) {
+		if name, _ := t.refList.GetItemText(i); name == tname {
+			t.refList.RemoveItem(i)
+			break
+		}
+	}
+	t.refresh()
+}
+
+// refresh redoes the search, keeping the current theme selected.
+func (t *Themer) refresh() {
+	cur, ok := t.GetTheme()
+	t.filter(t.search.GetText())
+	if !ok {
+		return
+	}
+	for i, tname := range t.names {
+		if tname == cur {
+			t.list.SetCurrentItem(i)
+			break
+		}
+	}
+	t.showStatus()
+}
+
 func (t *Themer) resetList(inds []int) {
 	t.list.Clear()
+	t.names = t.names[:0]
 
 	if inds == nil {
 		for i := 0; i < t.refList.GetItemCount(); i++ {
-			tname, _ := t.refList.GetItemText(i)
-			simpleAdd(t.list, tname)
+			inds = append(inds, i)
 		}
-		return
 	}
 	for _, i := range inds {
 		tname, _ := t.refList.GetItemText(i)
-		simpleAdd(t.list, tname)
+		t.addItem(tname)
+	}
+	t.showStatus()
+}
+
+func (t *Themer) addItem(tname string) {
+	t.list.AddItem(t.itemText(tname), "", 0, nil)
+	t.names = append(t.names, tname)
+}
+
+// broken themes are listed dimmed, and can't be picked
+func (t *Themer) itemText(tname string) string {
+	text := tview.Escape(tname)
+	if t.tm[tname].err != nil {
+		text = "[::d]" + text + "[::-]"
+	}
+	return text
+}
+
+// showStatus describes the current theme, or shows why it's broken.
+func (t *Themer) showStatus() {
+	t.status.Clear()
+	t.ResizeItem(t.status, 0, 0)
+
+	theme, ok := t.GetTheme()
+	if !ok {
+		return
+	}
+	e, found := t.tm[theme]
+	if !found {
+		return
+	}
+	if e.err != nil {
+		t.showError(e.err)
+		return
+	}
+
+	source := e.path
+	if source == "" {
+		source = "-"
+	}
+	t.status.SetWrap(false).SetText(fmt.Sprintf("[::b]%s[::-]\n[::d]%s[::-]\n%s, %s\n%s",
+		tview.Escape(e.meta.Name), tview.Escape(e.meta.Author),
+		e.meta.Variant, e.format, tview.Escape(source)))
+	t.ResizeItem(t.status, 4, 0)
+}
+
+func (t *Themer) showError(err error) {
+	t.status.SetWrap(true).SetText("[red]" + tview.Escape(err.Error()))
+	t.ResizeItem(t.status, 3, 0)
+}
+
+// Apply applies the current theme, and says in the status why when it
+// can't be.
+func (t *Themer) Apply() {
+	t.showStatus()
+	theme, ok := t.GetTheme()
+	if !ok {
+		return
+	}
+	if _, err := t.tm.Apply(theme); err != nil {
+		t.showError(err)
 	}
 }
 
 func (t *Themer) GetTheme() (string, bool) {
-	if t.list.GetItemCount() == 0 {
+	if len(t.names) == 0 {
 		return "", false
 	}
-	theme, _ := t.list.GetItemText(t.list.GetCurrentItem())
-	return theme, true
+	return t.names[t.list.GetCurrentItem()], true
 }
 
 func (t *Themer) done() {
 	theme, ok := t.GetTheme()
-	if !ok {
+	if !ok || t.tm[theme].err != nil {
 		return
 	}
 	t.doneFunc(theme)
@@ -131,10 +277,7 @@ func (t *Themer) InputHandler() func(*tcell.EventKey, func(tview.Primitive)) {
 			} else {
 				t.list.InputHandler()(tcell.NewEventKey(key, 0, tcell.ModNone), setFocus)
 			}
-
-			if theme, ok := t.GetTheme(); ok {
-				t.tm.Apply(theme)
-			}
+			t.Apply()
 		}
 
 		switch event.Key() {
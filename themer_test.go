@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func listed(th *Themer) []string {
+	var names []string
+	for i := 0; i < th.list.GetItemCount(); i++ {
+		name, _ := th.list.GetItemText(i)
+		names = append(names, name)
+	}
+	return names
+}
+
+// Adding themes one by one ends up where a fresh search would.
+func TestThemerAdd(t *testing.T) {
+	entry := &ThemeEntry{Theme: sampleTheme()}
+	broken := &ThemeEntry{err: fmt.Errorf("broken")}
+	for _, search := range []string{"", "o", "Gruv"} {
+		th := NewThemer().init(ThemeMap{"terminal": entry})
+		th.search.SetText(search)
+
+		var names []string
+		for i := 0; i < 50; i++ {
+			names = append(names, fmt.Sprintf("theme-%02d", i))
+		}
+		names = append(names, "gruvbox", "nord", "onedark")
+		rand.Shuffle(len(names), func(i, j int) { names[i], names[j] = names[j], names[i] })
+
+		var cur string
+		for i, tname := range names {
+			th.Add(tname, entry)
+			if c, ok := th.GetTheme(); ok && cur == "" && i >= 10 {
+				cur = c
+			}
+		}
+		th.Add("nord", broken)
+
+		got := listed(th)
+		th.refresh()
+		if want := listed(th); fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("search %q: got %v, want %v", search, got, want)
+		}
+		if now, _ := th.GetTheme(); now != cur {
+			t.Errorf("search %q: selection moved from %s to %s", search, cur, now)
+		}
+	}
+}
+
+func TestLoadBatches(t *testing.T) {
+	isolate(t)
+	dir := t.TempDir()
+	THEME_DIRS = []string{dir}
+	n := 3*LOAD_BATCH + 5
+	for i := 0; i < n; i++ {
+		touch(t, dir, fmt.Sprintf("theme-%03d.json", i))
+	}
+
+	seen := make(map[string]bool)
+	loadBatches(findThemes(), func(batch ThemeMap) {
+		if len(batch) > LOAD_BATCH {
+			t.Errorf("batch of %d", len(batch))
+		}
+		for tname := range batch {
+			if seen[tname] {
+				t.Errorf("%s loaded twice", tname)
+			}
+			seen[tname] = true
+		}
+	})
+	for i := 0; i < n; i++ {
+		if tname := fmt.Sprintf("theme-%03d", i); !seen[tname] {
+			t.Errorf("%s not loaded", tname)
+		}
+	}
+}
+
+func TestThemerApplyBroken(t *testing.T) {
+	th := NewThemer().init(ThemeMap{"broken": &ThemeEntry{err: fmt.Errorf("no colors")}})
+	th.Apply()
+	if got := th.status.GetText(true); !strings.Contains(got, "no colors") {
+		t.Errorf("status %q doesn't say why", got)
+	}
+}
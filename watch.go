@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"github.com/fsnotify/fsnotify"
+	"io/fs"
+	"log"
+	"time"
+)
+
+// WATCH_INTERVAL is how often WatchThemes looks for theme directories that
+// didn't exist when it started, or polls them all where they can't be
+// watched.
+const WATCH_INTERVAL = 2 * time.Second
+
+// WATCH_SETTLE is how long a burst of file events has to be over before the
+// search path is rescanned, since editors and git write in several steps.
+const WATCH_SETTLE = 100 * time.Millisecond
+
+// fileStamp is what a theme file is compared by between scans.
+type fileStamp struct {
+	path    string
+	modTime time.Time
+	size    int64
+}
+
+func stampOf(tf *themeFile) fileStamp {
+	s := fileStamp{path: tf.path}
+	if tf.info != nil {
+		s.modTime, s.size = tf.info.ModTime(), tf.info.Size()
+	}
+	return s
+}
+
+// WatchThemes rescans the search path whenever something in it changes,
+// starting from the files that were already loaded. Themes that are new,
+// edited, or now shadowed by a file earlier in the path are reloaded and
+// passed to changed; themes that are gone entirely are passed to removed.
+// Both are called from the watching goroutine. Directories are watched as
+// they appear, checked for every interval. It returns once quit is closed.
+func WatchThemes(files []*themeFile, interval time.Duration, quit <-chan bool, changed func(string, *ThemeEntry), removed func(string)) {
+	stamps := make(map[string]fileStamp)
+	for _, tf := range files {
+		stamps[tf.name] = stampOf(tf)
+	}
+	rescan := func() {
+		current := make(map[string]fileStamp)
+		for _, tf := range findThemes() {
+			s := stampOf(tf)
+			current[tf.name] = s
+			if old, found := stamps[tf.name]; !found || old.path != s.path ||
+				old.size != s.size || !old.modTime.Equal(s.modTime) {
+				changed(tf.name, tf.load())
+			}
+		}
+		for tname := range stamps {
+			if _, found := current[tname]; !found {
+				removed(tname)
+			}
+		}
+		stamps = current
+	}
+
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Println("couldn't watch themes, polling instead:", err)
+		for {
+			select {
+			case <-tick.C:
+				rescan()
+			case <-quit:
+				return
+			}
+		}
+	}
+	defer w.Close()
+
+	// directories that can't be watched are only reported once
+	watched := make(map[string]bool)
+	failed := make(map[string]bool)
+	watch := func() bool {
+		added := false
+		for _, dir := range ThemePath() {
+			if watched[dir] {
+				continue
+			}
+			err := w.Add(dir)
+			switch {
+			case err == nil:
+				watched[dir], added = true, true
+			case !errors.Is(err, fs.ErrNotExist) && !failed[dir]:
+				log.Println("couldn't watch", dir, err)
+				failed[dir] = true
+			}
+		}
+		return added
+	}
+	// anything that changed before the watches were in place
+	watch()
+	rescan()
+
+	var settle <-chan time.Time
+	for {
+		select {
+		case ev := <-w.Events:
+			if ev.Op&(fsnotify.Remove|fsnotify.Rename) != 0 && watched[ev.Name] {
+				delete(watched, ev.Name)
+			}
+			settle = time.After(WATCH_SETTLE)
+		case err := <-w.Errors:
+			log.Println("watching themes:", err)
+		case <-tick.C:
+			if watch() {
+				settle = time.After(WATCH_SETTLE)
+			}
+		case <-settle:
+			settle = nil
+			rescan()
+		case <-quit:
+			return
+		}
+	}
+}
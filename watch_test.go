@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatchThemes(t *testing.T) {
+	isolate(t)
+	dir := t.TempDir()
+	later := filepath.Join(t.TempDir(), "later")
+	THEME_DIRS = []string{dir, later}
+
+	files := findThemes()
+	events := make(chan string)
+	quit, done := make(chan bool), make(chan bool)
+	go func() {
+		defer close(done)
+		send := func(event string) {
+			select {
+			case events <- event:
+			case <-quit:
+			}
+		}
+		WatchThemes(files, 20*time.Millisecond, quit, func(tname string, e *ThemeEntry) {
+			send("changed " + tname)
+		}, func(tname string) {
+			send("removed " + tname)
+		})
+	}()
+	defer func() {
+		close(quit)
+		<-done
+	}()
+	expect := func(want string) {
+		t.Helper()
+		select {
+		case got := <-events:
+			if got != want {
+				t.Fatalf("got %q, want %q", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no %q", want)
+		}
+	}
+
+	put(t, dir, "one.json", "json", sampleTheme())
+	expect("changed one")
+	// directories are picked up once they exist
+	if err := os.Mkdir(later, 0755); err != nil {
+		t.Fatal(err)
+	}
+	put(t, later, "two.json", "json", sampleTheme())
+	expect("changed two")
+	if err := os.Remove(filepath.Join(dir, "one.json")); err != nil {
+		t.Fatal(err)
+	}
+	expect("removed one")
+}
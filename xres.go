@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"github.com/gdamore/tcell/v2"
+	"image/color"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	RE_xresDefine = regexp.MustCompile(`^#define\s+(\w+)\s+(.*\S)`)
+	RE_xresAssn   = regexp.MustCompile(`^[\w.*-]*?\b(color\d+|foreground|background)\s*:\s*(.*\S)`)
+	RE_xresMacro  = regexp.MustCompile(`^\w+$`)
+	RE_rgbSpec    = regexp.MustCompile(`^rgb:([0-9A-Fa-f]{1,4})/([0-9A-Fa-f]{1,4})/([0-9A-Fa-f]{1,4})$`)
+)
+
+// ParseXColor understands the color specs X clients and terminals use:
+// #rgb, #rrggbb and rgb:r/g/b with 1 to 4 hex digits per component.
+func ParseXColor(spec string) (color.RGBA, error) {
+	if match := RE_rgbSpec.FindStringSubmatch(spec); len(match) > 1 {
+		var comp [3]uint8
+		for i, h := range match[1:] {
+			val, _ := strconv.ParseUint(h, 16, 16)
+			top := uint64(1)<<(4*len(h)) - 1
+			comp[i] = uint8((val*0xff + top/2) / top)
+		}
+		return color.RGBA{comp[0], comp[1], comp[2], 0xff}, nil
+	}
+
+	hex := strings.TrimPrefix(spec, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	val, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil || len(hex) != 6 || hex == spec {
+		return color.RGBA{}, fmt.Errorf("bad color %q", spec)
+	}
+	return color.RGBA{uint8(val >> 16), uint8(val >> 8), uint8(val), 0xff}, nil
+}
+
+// readXresources reads an Xresources file or `xrdb -query` output. Simple
+// #define macros are resolved; other preprocessor lines are ignored, as are
+// resources other than the palette and foreground/background.
+func readXresources(file []byte) (Theme, error) {
+	defines := make(map[string]string)
+	values := make(map[string]string)
+
+	scan := bufio.NewScanner(bytes.NewReader(file))
+	for scan.Scan() {
+		line := strings.TrimSpace(scan.Text())
+		if match := RE_xresDefine.FindStringSubmatch(line); len(match) > 1 {
+			defines[match[1]] = match[2]
+		} else if match := RE_xresAssn.FindStringSubmatch(line); len(match) > 1 {
+			values[match[1]] = match[2]
+		}
+	}
+
+	// macros may refer to other macros
+	for key, val := range values {
+		for i := 0; i < 16 && RE_xresMacro.MatchString(val); i++ {
+			if def, found := defines[val]; found {
+				val = def
+			}
+		}
+		values[key] = val
+	}
+
+	palette := make(map[int]string)
+	for key, val := range values {
+		if index, found := shellKeyIndex(key); found {
+			palette[index] = val
+		}
+	}
+	return paletteColors(palette, values["foreground"], values["background"])
+}
+
+func writeXresources(w io.Writer, m Meta, t Theme) error {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "! %s\n\n", m.header())
+	for _, c := range []tcell.Color{Foreground, Background} {
+		fmt.Fprintf(&b, "*.%s: %s\n", cNames[c].string, hexColor(t[c].RGBA))
+	}
+	fmt.Fprintf(&b, "*.cursorColor: %s\n", hexColor(t[Foreground].RGBA))
+
+	for i, c := range t.Slots() {
+		b.WriteString("\n")
+		if name, found := cNames[c]; found {
+			fmt.Fprintf(&b, "! %s\n", name.string)
+		}
+		fmt.Fprintf(&b, "*.color%d: %s\n", i, hexColor(t[c].RGBA))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
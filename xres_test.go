@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+// grays is Xresources for a gray ramp, with the resources named by prefix.
+func grays(prefix string) string {
+	var b strings.Builder
+	for i := 0; i < 16; i++ {
+		fmt.Fprintf(&b, "%scolor%d:\t#%02x%02x%02x\n", prefix, i, i*16, i*16, i*16)
+	}
+	return b.String()
+}
+
+func TestXresourcesRoundTrip(t *testing.T) {
+	roundTrip(t, "xresources", sampleTheme())
+}
+
+func TestXresourcesDefine(t *testing.T) {
+	file := "! comment\n#ifdef COLOR\n#define base00 #181410\n#define bg base00\n" +
+		"#define fg   rgb:d/d/c\n" +
+		"*.background: bg\nURxvt*foreground:\tfg\n#endif\n" + grays("*")
+	theme, err := readXresources([]byte(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := theme[Background].RGBA; got != (color.RGBA{0x18, 0x14, 0x10, 0xff}) {
+		t.Errorf("background through two macros: got %v", got)
+	}
+	if got := theme[Foreground].RGBA; got != (color.RGBA{0xdd, 0xdd, 0xcc, 0xff}) {
+		t.Errorf("foreground: got %v", got)
+	}
+	if got := theme[BrightWhite].RGBA; got != (color.RGBA{0xf0, 0xf0, 0xf0, 0xff}) {
+		t.Errorf("color15: got %v", got)
+	}
+}
+
+func TestParseXColor(t *testing.T) {
+	for spec, want := range map[string]color.RGBA{
+		"#1a2b3c":            {0x1a, 0x2b, 0x3c, 0xff},
+		"#abc":               {0xaa, 0xbb, 0xcc, 0xff},
+		"rgb:ff/80/00":       {0xff, 0x80, 0x00, 0xff},
+		"rgb:ffff/8080/0000": {0xff, 0x80, 0x00, 0xff},
+		"rgb:f/8/0":          {0xff, 0x88, 0x00, 0xff},
+		"rgb:fff/000/7ff":    {0xff, 0x00, 0x7f, 0xff},
+	} {
+		if got, err := ParseXColor(spec); err != nil || got != want {
+			t.Errorf("%s: got %v, %v, want %v", spec, got, err, want)
+		}
+	}
+	for _, spec := range []string{"", "1a2b3c", "#1a2b3", "#1a2b3g", "rgb:ff/80", "rgb:fffff/0/0", "red"} {
+		if _, err := ParseXColor(spec); err == nil {
+			t.Errorf("%q: no error", spec)
+		}
+	}
+}
+
+// Without foreground and background, color7 and color0 stand in; but a
+// broken one is an error rather than quietly replaced.
+func TestXresourcesSpecial(t *testing.T) {
+	theme, err := readXresources([]byte(grays("*.")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if theme[Foreground].RGBA != theme[White].RGBA || theme[Background].RGBA != theme[Black].RGBA {
+		t.Errorf("foreground %v, background %v", theme[Foreground].RGBA, theme[Background].RGBA)
+	}
+
+	for _, line := range []string{"*.foreground: #12345\n", "*.background: nope\n"} {
+		if _, err := readXresources([]byte(grays("*.") + line)); err == nil {
+			t.Errorf("%q: no error", line)
+		}
+	}
+}
+
+func TestXresourcesMalformed(t *testing.T) {
+	for _, file := range []string{
+		"",
+		"*.color0: #000000\n*.color1: #ffffff\n",
+		strings.Replace(grays("*."), "#000000", "#00000", 1),
+		strings.Replace(grays("*."), "#000000", "undefined", 1),
+		strings.Replace(grays("*."), "*.color5", "*.colour5", 1),
+	} {
+		if _, err := readXresources([]byte(file)); err == nil {
+			t.Errorf("no error reading %q", file)
+		}
+	}
+}